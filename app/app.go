@@ -0,0 +1,233 @@
+// Package app 管理进程中各个组件 (数据库, kafka producer/consumer, http server 等) 的启动和停止.
+//
+// 组件按注册顺序启动, 按相反的顺序停止: 先停止接收请求的 http server 和 consumer, 最后关闭它们依赖的数据库.
+// 收到 SIGINT/SIGTERM 之后在 ShutdownTimeout 内停止所有组件, 等待处理中的请求和消息完成.
+package app
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultStartTimeout    = 30 * time.Second
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// Component 是由 App 管理的组件.
+type Component interface {
+	// Start 启动组件, 启动完成之后返回. 长时间运行的部分需要在后台 goroutine 中执行, 见 Runner.
+	Start(ctx context.Context) error
+
+	// Shutdown 停止组件, 等待处理中的任务完成, ctx 结束时放弃等待并返回错误.
+	// 只会对 Start 成功的组件调用一次.
+	Shutdown(ctx context.Context) error
+}
+
+// Runner 是启动之后在后台运行的组件, 例如 http server 和 consumer.
+type Runner interface {
+	Component
+
+	// Exited 返回的 channel 在组件不是因为 Shutdown 而提前退出时收到退出的原因, App 随之停止所有组件.
+	Exited() <-chan error
+}
+
+// Config 是 App 相关配置.
+type Config struct {
+	StartTimeout    time.Duration // 可选; 启动所有组件的超时时间, 默认 30s
+	ShutdownTimeout time.Duration // 可选; 停止所有组件的总超时时间, 默认 10s
+	Signals         []os.Signal   // 可选; 触发停止的信号, 默认 SIGINT 和 SIGTERM
+	Logger          logger.Logger // 可选; 日志, 默认 logger.Default()
+}
+
+// App 按顺序启动组件, 收到信号或者组件退出时按相反的顺序停止.
+type App struct {
+	startTimeout    time.Duration
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	logger          logger.Logger
+
+	mu         sync.Mutex
+	components []namedComponent
+	lifecycle  common.Lifecycle
+}
+
+type namedComponent struct {
+	name string
+	Component
+}
+
+// New 创建 App.
+func New(config Config) *App {
+	if config.StartTimeout <= 0 {
+		config.StartTimeout = defaultStartTimeout
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if len(config.Signals) == 0 {
+		config.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	return &App{
+		startTimeout:    config.StartTimeout,
+		shutdownTimeout: config.ShutdownTimeout,
+		signals:         config.Signals,
+		logger:          config.Logger,
+	}
+}
+
+// Register 注册组件, 组件按注册顺序启动, 按相反的顺序停止. 只能在 Run 之前调用, name 不能重复.
+func (a *App) Register(name string, c Component) error {
+	if name == "" {
+		return errors.New("empty component name")
+	}
+	if c == nil {
+		return fmt.Errorf("nil component %s", name)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if state := a.lifecycle.State(); state != common.StateNew {
+		return &common.StateError{Op: "register component", State: state}
+	}
+	for _, registered := range a.components {
+		if registered.name == name {
+			return fmt.Errorf("duplicate component %s", name)
+		}
+	}
+	a.components = append(a.components, namedComponent{name: name, Component: c})
+	return nil
+}
+
+// State 返回 App 的状态, 开始停止之后是 common.StateStopping.
+func (a *App) State() common.State {
+	return a.lifecycle.State()
+}
+
+// Run 启动所有组件并阻塞, 直到收到信号, ctx 结束或者某个 Runner 提前退出, 然后停止所有组件.
+//
+// 某个组件启动失败时停止已经启动的组件, 返回启动错误. 组件提前退出或者停止失败时返回的错误不为 nil,
+// 调用方应当以非 0 状态码退出. 只能调用一次.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.lifecycle.BeginStart(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	components := a.components
+	a.mu.Unlock()
+
+	// 在启动之前监听信号, 启动期间收到的信号也会停止启动
+	sigCtx, stop := signal.NotifyContext(ctx, a.signals...)
+	defer stop()
+
+	started, err := a.start(sigCtx, components)
+	var exitErr error
+	switch {
+	case err != nil && sigCtx.Err() != nil:
+		// 启动期间收到信号或者 ctx 结束, 不是启动失败, 直接停止已经启动的组件
+		a.logger.Info(ctx, "app-start-interrupted", "error", err.Error())
+		err = nil
+	case err != nil:
+		a.lifecycle.Fail(err)
+	default:
+		if err = a.lifecycle.MarkRunning(); err == nil {
+			a.logger.Info(ctx, "app-started", "components", len(components))
+			if exitErr = a.wait(sigCtx, started); exitErr != nil {
+				a.lifecycle.Fail(exitErr)
+			}
+		}
+	}
+
+	// 停止期间再次收到信号时不再等待, 立即停止
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	force, stopForce := signal.NotifyContext(shutdownCtx, a.signals...)
+	defer stopForce()
+
+	stopErr := a.lifecycle.Stop(force, func(ctx context.Context) error {
+		return a.shutdown(ctx, started)
+	})
+	if err != nil {
+		return err
+	}
+	if exitErr != nil {
+		return exitErr
+	}
+	return stopErr
+}
+
+// start 按顺序启动组件, 返回已经启动的组件.
+func (a *App) start(ctx context.Context, components []namedComponent) ([]namedComponent, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.startTimeout)
+	defer cancel()
+	started := make([]namedComponent, 0, len(components))
+	for _, c := range components {
+		if err := ctx.Err(); err != nil {
+			return started, fmt.Errorf("start %s: %w", c.name, err)
+		}
+		begin := time.Now()
+		if err := c.Start(ctx); err != nil {
+			a.logger.Error(ctx, "app-component-start-failed", "component", c.name, "error", err.Error())
+			return started, fmt.Errorf("start %s: %w", c.name, err)
+		}
+		started = append(started, c)
+		a.logger.Info(ctx, "app-component-started", "component", c.name, "latency", time.Since(begin).String())
+	}
+	return started, nil
+}
+
+// wait 等待 ctx 结束或者某个 Runner 提前退出, 返回 Runner 的退出原因.
+func (a *App) wait(ctx context.Context, started []namedComponent) error {
+	exited := make(chan error, len(started))
+	for _, c := range started {
+		runner, ok := c.Component.(Runner)
+		if !ok {
+			continue
+		}
+		go func(name string, runner Runner) {
+			select {
+			case err := <-runner.Exited():
+				if err == nil {
+					err = errors.New("exited unexpectedly")
+				}
+				exited <- fmt.Errorf("%s: %w", name, err)
+			case <-ctx.Done():
+			}
+		}(c.name, runner)
+	}
+	select {
+	case <-ctx.Done():
+		a.logger.Info(ctx, "app-stopping", "reason", context.Cause(ctx).Error())
+		return nil
+	case err := <-exited:
+		a.logger.Error(ctx, "app-component-exited", "error", err.Error())
+		return err
+	}
+}
+
+// shutdown 按相反的顺序停止组件, 一个组件停止失败不影响其他组件, 返回所有错误.
+func (a *App) shutdown(ctx context.Context, started []namedComponent) error {
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		begin := time.Now()
+		if err := c.Shutdown(ctx); err != nil {
+			a.logger.Error(ctx, "app-component-shutdown-failed", "component", c.name, "error", err.Error())
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", c.name, err))
+			continue
+		}
+		a.logger.Info(ctx, "app-component-stopped", "component", c.name, "latency", time.Since(begin).String())
+	}
+	return errors.Join(errs...)
+}
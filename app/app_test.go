@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// recorder 记录组件启动和停止的顺序.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) hook(name string, startErr error) Hook {
+	return Hook{
+		OnStart: func(context.Context) error {
+			r.record("start " + name)
+			return startErr
+		},
+		OnStop: func(context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func newTestApp(config Config) *App {
+	config.Logger = logger.Nop()
+	return New(config)
+}
+
+// runUntilStarted 在所有组件启动之后停止 a.
+func runUntilStarted(a *App) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.lifecycle.WaitFor(ctx, common.StateRunning)
+		cancel()
+	}()
+	return a.Run(ctx)
+}
+
+func TestApp_Run(t *testing.T) {
+	errStart := errors.New("dial failed")
+	tests := []struct {
+		name    string
+		fail    string // 启动失败的组件
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "stop in reverse order",
+			want: []string{"start db", "start producer", "start server", "stop server", "stop producer", "stop db"},
+		},
+		{
+			name:    "start failed",
+			fail:    "producer",
+			want:    []string{"start db", "start producer", "stop db"},
+			wantErr: errStart,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(Config{})
+			r := &recorder{}
+			for _, name := range []string{"db", "producer", "server"} {
+				var err error
+				if name == tt.fail {
+					err = errStart
+				}
+				if err := a.Register(name, r.hook(name, err)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := runUntilStarted(a)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(r.events, tt.want) {
+				t.Errorf("events = %q, want %q", r.events, tt.want)
+			}
+		})
+	}
+}
+
+func TestApp_Register(t *testing.T) {
+	a := newTestApp(Config{})
+	if err := a.Register("db", Hook{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Register("db", Hook{}); err == nil {
+		t.Errorf("Register() duplicate error = nil")
+	}
+	if err := a.Register("", Hook{}); err == nil {
+		t.Errorf("Register() empty name error = nil")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Register("cache", Hook{}); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("Register() after Run error = %v", err)
+	}
+	if err := a.Run(context.Background()); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("second Run() error = %v", err)
+	}
+}
+
+func TestApp_RunnerExited(t *testing.T) {
+	a := newTestApp(Config{})
+	r := &recorder{}
+	errLost := errors.New("connection lost")
+	_ = a.Register("db", r.hook("db", nil))
+	_ = a.Register("consumer", NewService(
+		func(context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return errLost
+		},
+		func(context.Context) error { return nil },
+	))
+	err := a.Run(context.Background())
+	if !errors.Is(err, errLost) || a.State() != common.StateFailed {
+		t.Errorf("Run() error = %v, State() = %v", err, a.State())
+	}
+	if want := []string{"start db", "stop db"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %q, want %q", r.events, want)
+	}
+}
+
+func TestApp_StartInterrupted(t *testing.T) {
+	a := newTestApp(Config{})
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = a.Register("db", r.hook("db", nil))
+	_ = a.Register("producer", Hook{OnStart: func(context.Context) error {
+		cancel()
+		return nil
+	}})
+	_ = a.Register("server", r.hook("server", nil))
+	// 启动期间收到停止信号不是错误, 没有启动的组件不会启动
+	if err := a.Run(ctx); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if want := []string{"start db", "stop db"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %q, want %q", r.events, want)
+	}
+}
+
+func TestApp_ShutdownTimeout(t *testing.T) {
+	a := newTestApp(Config{ShutdownTimeout: 10 * time.Millisecond})
+	r := &recorder{}
+	_ = a.Register("db", r.hook("db", nil))
+	_ = a.Register("consumer", Hook{OnStop: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	err := runUntilStarted(a)
+	if !errors.Is(err, context.DeadlineExceeded) || a.State() != common.StateFailed {
+		t.Errorf("Run() error = %v, State() = %v", err, a.State())
+	}
+	// 超时之后仍然停止剩下的组件
+	if want := []string{"start db", "stop db"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %q, want %q", r.events, want)
+	}
+}
+
+func TestApp_Signal(t *testing.T) {
+	a := newTestApp(Config{Signals: []os.Signal{syscall.SIGUSR1}})
+	server := NewHTTPServer(&http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 收到信号时请求正在处理, 停止时需要等待它完成
+			_ = syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+			time.Sleep(20 * time.Millisecond)
+			_, _ = io.WriteString(w, "ok")
+		}),
+	})
+	_ = a.Register("server", server)
+	errc := make(chan error, 1)
+	go func() { errc <- a.Run(context.Background()) }()
+	if err := a.lifecycle.WaitFor(context.Background(), common.StateRunning); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/", server.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	if _, err := http.Get(fmt.Sprintf("http://%s/", server.Addr())); err == nil {
+		t.Errorf("server still serving after shutdown")
+	}
+}
+
+func TestHTTPServer_StartFailed(t *testing.T) {
+	first := NewHTTPServer(&http.Server{Addr: "127.0.0.1:0"})
+	if err := first.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Shutdown(context.Background())
+	// 端口被占用是启动错误
+	second := NewHTTPServer(&http.Server{Addr: first.Addr().String()})
+	if err := second.Start(context.Background()); err == nil {
+		t.Errorf("Start() on used port error = nil")
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Hook 把启动和停止函数适配为 Component, 用于数据库连接, producer 等只需要在停止时释放的资源.
+type Hook struct {
+	OnStart func(ctx context.Context) error // 可选; 启动时调用
+	OnStop  func(ctx context.Context) error // 可选; 停止时调用
+}
+
+func (h Hook) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+func (h Hook) Shutdown(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+// Closer 把 Close 适配为停止时调用的 Component, 例如 *sql.DB.
+func Closer(fn func() error) Component {
+	return Hook{OnStop: func(context.Context) error { return fn() }}
+}
+
+// Service 把阻塞运行的函数适配为 Runner, 例如 kafka.Consumer 的 StartConsumeMessage 和 Close.
+type Service struct {
+	run    func(ctx context.Context) error
+	stop   func(ctx context.Context) error
+	exited chan error
+	done   chan struct{}
+}
+
+// NewService 创建 Service. run 在后台 goroutine 中执行, 阻塞直到 stop 被调用; stop 之前返回时 App 停止所有组件.
+func NewService(run func(ctx context.Context) error, stop func(ctx context.Context) error) *Service {
+	return &Service{run: run, stop: stop, exited: make(chan error, 1), done: make(chan struct{})}
+}
+
+// Start 在后台执行 run, run 使用的 ctx 不会因为启动超时而结束.
+func (s *Service) Start(ctx context.Context) error {
+	go func() {
+		defer close(s.done)
+		s.exited <- s.run(context.WithoutCancel(ctx))
+	}()
+	return nil
+}
+
+// Shutdown 调用 stop 并等待 run 返回.
+func (s *Service) Shutdown(ctx context.Context) error {
+	err := s.stop(ctx)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+func (s *Service) Exited() <-chan error { return s.exited }
+
+// HTTPServer 把 *http.Server 适配为 Runner: 启动时监听端口, 端口被占用等错误是启动错误;
+// 停止时不再接收新连接, 等待处理中的请求完成.
+type HTTPServer struct {
+	server *http.Server
+	addr   net.Addr // Start 之后监听的地址
+	exited chan error
+}
+
+// NewHTTPServer 创建 HTTPServer.
+func NewHTTPServer(server *http.Server) *HTTPServer {
+	return &HTTPServer{server: server, exited: make(chan error, 1)}
+}
+
+func (s *HTTPServer) Start(context.Context) error {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.addr = ln.Addr()
+	go func() {
+		if err := s.server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			s.exited <- err
+		}
+	}()
+	return nil
+}
+
+// Shutdown 调用 http.Server.Shutdown, ctx 结束时强制关闭剩余的连接.
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		_ = s.server.Close()
+	}
+	return err
+}
+
+func (s *HTTPServer) Exited() <-chan error { return s.exited }
+
+// Addr 返回监听的地址, Addr 为 ":0" 时可以得到实际的端口, Start 之前为 nil.
+func (s *HTTPServer) Addr() net.Addr { return s.addr }
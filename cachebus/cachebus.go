@@ -0,0 +1,232 @@
+// Package cachebus 通过 kafka 消息总线在多个实例之间同步本地缓存的失效.
+//
+// 写操作之后 Broadcaster 发送 CacheInvalidate 消息, 每个实例的 Listener 使用自己独立的 consumer group
+// 消费全部消息, 删除本地缓存中对应的 key. 实例忽略自己发出的消息, 每次加入 consumer group 之后删除所有缓存,
+// 因为停止期间以及加入之前的消息已经错过了. 实例的 group 每次启动都不同, 停止时需要删除, 见 InstanceGroup.
+package cachebus
+
+import (
+	"context"
+	"crypto/rand"
+	"demo-to-start/common"
+	"demo-to-start/kafka"
+	"demo-to-start/logger"
+	"demo-to-start/proto/cachepb"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MessageTypeCacheInvalidate 是 CacheInvalidate 消息的 MessageType.
+const MessageTypeCacheInvalidate kafka.MessageType = 1001
+
+// NewInstanceID 返回当前进程的实例标识, 格式为 hostname-随机数, 每次启动都不同.
+func NewInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return host + "-" + hex.EncodeToString(b[:])
+}
+
+// InstanceGroup 返回实例独立的 consumer group, 每个实例都需要收到全部的失效消息, 不能共用 group.
+// group 每次启动都不同, consumer 需要设置 kafka.ConsumerConfig.DeleteGroupOnClose, 否则每次发布都会在 broker 上留下无用的 group.
+func InstanceGroup(prefix, instanceID string) string {
+	return prefix + "." + instanceID
+}
+
+// BroadcasterConfig 是 Broadcaster 相关配置.
+type BroadcasterConfig struct {
+	Producer    kafka.Producer    // 必须; 发送失效消息
+	InstanceID  string            // 必须; 当前实例标识, 和 Listener 相同, 通常使用 NewInstanceID
+	MessageType kafka.MessageType // 可选; 默认 MessageTypeCacheInvalidate
+}
+
+// Broadcaster 通知所有实例删除缓存.
+type Broadcaster struct {
+	producer    kafka.Producer
+	instanceID  string
+	messageType kafka.MessageType
+}
+
+// NewBroadcaster 创建 Broadcaster.
+func NewBroadcaster(config BroadcasterConfig) (*Broadcaster, error) {
+	if config.Producer == nil {
+		return nil, errors.New("nil producer")
+	}
+	if config.InstanceID == "" {
+		return nil, errors.New("empty instance id")
+	}
+	if config.MessageType == 0 {
+		config.MessageType = MessageTypeCacheInvalidate
+	}
+	return &Broadcaster{producer: config.Producer, instanceID: config.InstanceID, messageType: config.MessageType}, nil
+}
+
+// Invalidate 通知所有实例删除缓存 cache 中的 keys, keys 为空时删除全部.
+// 当前实例的本地缓存需要调用方自己删除.
+func (b *Broadcaster) Invalidate(ctx context.Context, cache string, keys ...string) error {
+	msg := &cachepb.CacheInvalidate{
+		Cache:       cache,
+		Keys:        keys,
+		InstanceId:  b.instanceID,
+		TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	return b.producer.SendMessage(ctx, b.messageType, msg)
+}
+
+// Invalidator 是可以被远程失效的本地缓存.
+type Invalidator interface {
+	// InvalidateKeys 删除 keys, 只删除本地缓存, 不能再广播.
+	InvalidateKeys(keys []string)
+
+	// InvalidateAll 删除全部 key.
+	InvalidateAll()
+}
+
+// purger 是 common.LRU 和 common.SyncLRU 的 Remove 和 Purge 方法.
+type purger[K comparable] interface {
+	Remove(key K) error
+	Purge()
+}
+
+// CacheInvalidator 把 common.LRU, common.SyncLRU 适配为 Invalidator, parseKey 把消息中的 key 转换为缓存的 key.
+// 并发访问时需要使用 common.SyncLRU.
+func CacheInvalidator[K comparable](cache purger[K], parseKey func(string) (K, error)) Invalidator {
+	return &cacheInvalidator[K]{cache: cache, parseKey: parseKey}
+}
+
+type cacheInvalidator[K comparable] struct {
+	cache    purger[K]
+	parseKey func(string) (K, error)
+}
+
+func (c *cacheInvalidator[K]) InvalidateKeys(keys []string) {
+	for _, s := range keys {
+		key, err := c.parseKey(s)
+		if err != nil {
+			continue // 无法解析的 key 不可能在缓存中
+		}
+		_ = c.cache.Remove(key)
+	}
+}
+
+func (c *cacheInvalidator[K]) InvalidateAll() {
+	c.cache.Purge()
+}
+
+// ListenerConfig 是 Listener 相关配置.
+type ListenerConfig struct {
+	Consumer    kafka.Consumer         // 必须; group 必须是实例独立的, 见 InstanceGroup; ConsumerConfig.OnAssigned 需要调用 Listener.OnAssigned
+	InstanceID  string                 // 必须; 当前实例标识, 和 Broadcaster 相同
+	Caches      map[string]Invalidator // 必须; 缓存名称到本地缓存
+	MessageType kafka.MessageType      // 可选; 默认 MessageTypeCacheInvalidate
+	Logger      logger.Logger          // 可选; 日志, 默认 logger.Default()
+
+	// 可选; 为 true 时第一次加入 consumer group 不清空缓存, 用于从快照恢复的缓存, 停止期间其他实例的修改最多 TTL 之后可见
+	KeepOnStart bool
+}
+
+// Listener 消费失效消息并删除本地缓存.
+type Listener struct {
+	consumer    kafka.Consumer
+	instanceID  string
+	messageType kafka.MessageType
+	logger      logger.Logger
+	keepOnStart bool
+	assigned    common.Bool            // 是否已经加入过 consumer group
+	caches      map[string]Invalidator // 创建之后只读
+}
+
+// NewListener 创建 Listener.
+//
+// NOTE: 不要忘记调用 Listener.Close, 否则会有资源泄漏.
+func NewListener(config ListenerConfig) (*Listener, error) {
+	if config.Consumer == nil {
+		return nil, errors.New("nil consumer")
+	}
+	if config.InstanceID == "" {
+		return nil, errors.New("empty instance id")
+	}
+	if len(config.Caches) == 0 {
+		return nil, errors.New("empty caches")
+	}
+	if config.MessageType == 0 {
+		config.MessageType = MessageTypeCacheInvalidate
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	caches := make(map[string]Invalidator, len(config.Caches))
+	for name, cache := range config.Caches {
+		if cache == nil {
+			return nil, fmt.Errorf("nil cache %s", name)
+		}
+		caches[name] = cache
+	}
+	return &Listener{
+		consumer:    config.Consumer,
+		instanceID:  config.InstanceID,
+		messageType: config.MessageType,
+		logger:      config.Logger,
+		keepOnStart: config.KeepOnStart,
+		caches:      caches,
+	}, nil
+}
+
+// Start 开始消费失效消息, 阻塞直到 Close 被调用.
+func (l *Listener) Start(ctx context.Context) error {
+	l.logger.Info(ctx, "cache-bus-listener-started", "instance_id", l.instanceID)
+	return l.consumer.StartConsumeMessage(ctx, map[kafka.MessageType]kafka.MessageHandler{l.messageType: l})
+}
+
+// OnAssigned 删除所有缓存, 用作 kafka.ConsumerConfig.OnAssigned. KeepOnStart 时第一次加入 consumer group 不删除.
+//
+// 新的 group 从最新位置开始消费, 停止期间以及加入 group 之前的消息已经错过了; rebalance 和重连期间也可能错过消息,
+// 缓存可能是旧的. 在加入之后才删除, 删除之后加载的缓存最多只会错过分配 partitions 到开始消费之间的消息.
+func (l *Listener) OnAssigned(ctx context.Context) {
+	if first := !l.assigned.Swap(true); first && l.keepOnStart {
+		return
+	}
+	for _, cache := range l.caches {
+		cache.InvalidateAll()
+	}
+	l.logger.Info(ctx, "cache-bus-caches-invalidated", "instance_id", l.instanceID)
+}
+
+// Close 停止消费.
+func (l *Listener) Close(ctx context.Context) error {
+	return l.consumer.Close(ctx)
+}
+
+// Check 检查 consumer 是否正常消费, 实现 health.Checker.
+func (l *Listener) Check(ctx context.Context) error {
+	return l.consumer.Check(ctx)
+}
+
+// ServeMessage 实现 kafka.MessageHandler.
+func (l *Listener) ServeMessage(ctx context.Context, msg *kafka.Message) error {
+	var invalidate cachepb.CacheInvalidate
+	if err := kafka.Unmarshal(msg.Value, &invalidate); err != nil {
+		return fmt.Errorf("invalid cache invalidate message: %w", err)
+	}
+	if invalidate.InstanceId == l.instanceID {
+		return nil // 自己发出的消息, 本地缓存已经删除了
+	}
+
+	cache, ok := l.caches[invalidate.Cache]
+	if !ok {
+		return nil // 其他服务的缓存
+	}
+	if len(invalidate.Keys) == 0 {
+		cache.InvalidateAll()
+	} else {
+		cache.InvalidateKeys(invalidate.Keys)
+	}
+	l.logger.Debug(ctx, "cache-invalidated", "cache", invalidate.Cache, "keys", len(invalidate.Keys), "from", invalidate.InstanceId)
+	return nil
+}
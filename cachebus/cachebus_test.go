@@ -0,0 +1,174 @@
+package cachebus
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/kafka"
+	"demo-to-start/proto/cachepb"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// memoryBus 是内存中的消息总线, 同时实现 kafka.Producer 和 kafka.Consumer, 发送的消息直接投递给所有 handler.
+type memoryBus struct {
+	sent     []*cachepb.CacheInvalidate
+	handlers []kafka.MessageHandler
+	closing  chan struct{}
+}
+
+func newMemoryBus() *memoryBus { return &memoryBus{closing: make(chan struct{})} }
+
+func (b *memoryBus) SendMessage(ctx context.Context, msgType kafka.MessageType, msg proto.Message, _ ...kafka.SendMessageOption) error {
+	if msgType != MessageTypeCacheInvalidate {
+		return nil
+	}
+	b.sent = append(b.sent, proto.Clone(msg).(*cachepb.CacheInvalidate))
+	value, err := kafka.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	for _, h := range b.handlers {
+		if err := h.ServeMessage(ctx, &kafka.Message{Value: value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memoryBus) StartConsumeMessage(_ context.Context, handlers map[kafka.MessageType]kafka.MessageHandler) error {
+	<-b.closing
+	return nil
+}
+
+func (b *memoryBus) Check(context.Context) error { return nil }
+
+func (b *memoryBus) Close(context.Context) error {
+	close(b.closing)
+	return nil
+}
+
+// recordingInvalidator 记录删除的 key.
+type recordingInvalidator struct {
+	keys []string
+	all  int
+}
+
+func (r *recordingInvalidator) InvalidateKeys(keys []string) { r.keys = append(r.keys, keys...) }
+func (r *recordingInvalidator) InvalidateAll()               { r.all++ }
+
+func TestBroadcastAndListen(t *testing.T) {
+	ctx := context.Background()
+	bus := newMemoryBus()
+
+	// 两个实例 a 和 b 共用同一个总线
+	var caches []*recordingInvalidator
+	var broadcasters []*Broadcaster
+	for _, id := range []string{"a", "b"} {
+		cache := &recordingInvalidator{}
+		l, err := NewListener(ListenerConfig{Consumer: bus, InstanceID: id, Caches: map[string]Invalidator{"users": cache}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus.handlers = append(bus.handlers, l)
+		b, err := NewBroadcaster(BroadcasterConfig{Producer: bus, InstanceID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches = append(caches, cache)
+		broadcasters = append(broadcasters, b)
+	}
+
+	if err := broadcasters[0].Invalidate(ctx, "users", "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := broadcasters[1].Invalidate(ctx, "orders", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := broadcasters[1].Invalidate(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bus.sent[0]; got.Cache != "users" || !reflect.DeepEqual(got.Keys, []string{"1", "2"}) || got.InstanceId != "a" || got.TimestampMs == 0 {
+		t.Errorf("sent message = %v", got)
+	}
+	// a 忽略自己发出的消息, 收到 b 的删除全部
+	if len(caches[0].keys) != 0 || caches[0].all != 1 {
+		t.Errorf("instance a invalidated keys = %v, all = %d", caches[0].keys, caches[0].all)
+	}
+	// b 收到 a 的消息, 忽略其他缓存和自己的消息
+	if !reflect.DeepEqual(caches[1].keys, []string{"1", "2"}) || caches[1].all != 0 {
+		t.Errorf("instance b invalidated keys = %v, all = %d", caches[1].keys, caches[1].all)
+	}
+}
+
+func TestListener_OnAssigned(t *testing.T) {
+	tests := []struct {
+		name        string
+		keepOnStart bool
+		wantAll     int
+	}{
+		{name: "invalidate", wantAll: 2},
+		{name: "keep", keepOnStart: true, wantAll: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := newMemoryBus()
+			cache := &recordingInvalidator{}
+			l, err := NewListener(ListenerConfig{Consumer: bus, InstanceID: "a", Caches: map[string]Invalidator{"users": cache}, KeepOnStart: tt.keepOnStart})
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan error)
+			go func() { done <- l.Start(context.Background()) }()
+			// 启动之后加入 consumer group, 然后 rebalance 重新加入
+			l.OnAssigned(context.Background())
+			l.OnAssigned(context.Background())
+			if err := l.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if cache.all != tt.wantAll {
+				t.Errorf("InvalidateAll called %d times, want %d", cache.all, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestListener_InvalidMessage(t *testing.T) {
+	l, err := NewListener(ListenerConfig{Consumer: newMemoryBus(), InstanceID: "a", Caches: map[string]Invalidator{"users": &recordingInvalidator{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.ServeMessage(context.Background(), &kafka.Message{Value: []byte{0xff}}); err == nil {
+		t.Errorf("ServeMessage() error = nil, want invalid message")
+	}
+}
+
+func TestCacheInvalidator(t *testing.T) {
+	lru := common.NewSyncLRU[int, string](10, 2, nil)
+	for i := 1; i <= 3; i++ {
+		_ = lru.Add(i, strconv.Itoa(i))
+	}
+	inv := CacheInvalidator[int](lru, strconv.Atoi)
+	inv.InvalidateKeys([]string{"1", "x", "9"})
+	var keys []int
+	for i := 1; i <= 3; i++ {
+		if lru.Contains(i) {
+			keys = append(keys, i)
+		}
+	}
+	sort.Ints(keys)
+	if !reflect.DeepEqual(keys, []int{2, 3}) {
+		t.Errorf("keys after InvalidateKeys = %v", keys)
+	}
+	inv.InvalidateAll()
+	if lru.Len() != 0 {
+		t.Errorf("Len() after InvalidateAll = %d", lru.Len())
+	}
+}
@@ -0,0 +1,120 @@
+// schemacheck 检查登记的 MessageType 的 proto schema 变更是否兼容, 不兼容时以非 0 退出, 用于阻止构建.
+//
+// 用法:
+//
+//	protoc --include_imports --descriptor_set_out=current.binpb -I proto proto/*.proto
+//	go run ./cmd/schemacheck -descriptor_set current.binpb            # 检查
+//	go run ./cmd/schemacheck -descriptor_set current.binpb -accept    # 检查通过后更新 schemas 下的快照
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"demo-to-start/kafka"
+	"demo-to-start/schema"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func main() {
+	var (
+		dir           = flag.String("dir", "schemas", "已接受的 schema 快照目录, 包含 registry.json")
+		descriptorSet = flag.String("descriptor_set", "", "当前 proto 的 FileDescriptorSet, 由 protoc --include_imports --descriptor_set_out 生成")
+		mode          = flag.String("mode", "", "兼容性规则 backward/forward/full, 默认使用 registry.json 中的配置")
+		accept        = flag.Bool("accept", false, "检查通过后把当前 schema 保存为快照, 新登记的 MessageType 必须使用该参数生成首个快照")
+	)
+	flag.Parse()
+
+	if err := run(*dir, *descriptorSet, *mode, *accept); err != nil {
+		fmt.Fprintln(os.Stderr, "schemacheck:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, descriptorSet, modeFlag string, accept bool) error {
+	if descriptorSet == "" {
+		return fmt.Errorf("-descriptor_set is required")
+	}
+	registry, err := schema.LoadRegistry(dir)
+	if err != nil {
+		return err
+	}
+	types, err := registry.Types()
+	if err != nil {
+		return err
+	}
+	mode, err := registry.CompatibilityMode()
+	if modeFlag != "" {
+		mode, err = schema.ParseMode(modeFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := schema.ReadDescriptorSet(descriptorSet)
+	if err != nil {
+		return err
+	}
+	currentFiles, err := protodesc.NewFiles(current)
+	if err != nil {
+		return fmt.Errorf("invalid descriptor set %s: %w", descriptorSet, err)
+	}
+
+	msgTypes := make([]kafka.MessageType, 0, len(types))
+	for msgType := range types {
+		msgTypes = append(msgTypes, msgType)
+	}
+	sort.Slice(msgTypes, func(i, j int) bool { return msgTypes[i] < msgTypes[j] })
+
+	failed := false
+	for _, msgType := range msgTypes {
+		name := protoreflect.FullName(types[msgType])
+		snapshot, err := schema.LoadSnapshot(dir, msgType)
+		switch {
+		case os.IsNotExist(err):
+			if !accept {
+				fmt.Printf("FAIL message type %s (%s): no accepted snapshot, run with -accept to record it\n", msgType.String(), name)
+				failed = true
+				continue
+			}
+		case err != nil:
+			return err
+		default:
+			snapshotFiles, err := protodesc.NewFiles(snapshot)
+			if err != nil {
+				return fmt.Errorf("invalid snapshot of message type %s: %w", msgType.String(), err)
+			}
+			violations, err := schema.CheckMessage(snapshotFiles, currentFiles, msgType, name, mode)
+			if err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				for _, v := range violations {
+					fmt.Println("FAIL", v.String())
+				}
+				failed = true
+				continue
+			}
+		}
+
+		fmt.Printf("ok   message type %s (%s)\n", msgType.String(), name)
+		if accept {
+			set, err := schema.Extract(currentFiles, name)
+			if err != nil {
+				return err
+			}
+			if err := schema.SaveSnapshot(dir, msgType, set); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("schema check failed under %s rules", mode)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package common
+
+// ARC 是自适应替换缓存 (Adaptive Replacement Cache): t1 保存只访问过一次的 key, t2 保存访问过多次的 key,
+// b1 和 b2 分别记录从 t1 和 t2 淘汰的 key. 根据 b1, b2 的命中情况动态调整 t1 的目标大小 p,
+// 在偏向最近访问和偏向访问频率之间自动平衡.
+type ARC[K comparable, V any] struct {
+	size int
+	p    int // t1 的目标大小
+
+	t1 *LRU[K, V]
+	t2 *LRU[K, V]
+	b1 *LRU[K, struct{}]
+	b2 *LRU[K, struct{}]
+}
+
+// NewARC 创建容量为 size 的 ARC 缓存, size 必须大于 0.
+func NewARC[K comparable, V any](size int) *ARC[K, V] {
+	if size <= 0 {
+		size = 1
+	}
+	return &ARC[K, V]{
+		size: size,
+		t1:   NewLRU[K, V](0, nil),
+		t2:   NewLRU[K, V](0, nil),
+		b1:   NewLRU[K, struct{}](size, nil),
+		b2:   NewLRU[K, struct{}](size, nil),
+	}
+}
+
+func (c *ARC[K, V]) Get(key K) (V, bool) {
+	if v, ok := c.t1.Peek(key); ok {
+		_ = c.t1.Remove(key)
+		_ = c.t2.Add(key, v)
+		return v, true
+	}
+	return c.t2.Get(key)
+}
+
+func (c *ARC[K, V]) Add(key K, value V) error {
+	if c.t1.Contains(key) {
+		_ = c.t1.Remove(key)
+		return c.t2.Add(key, value)
+	}
+	if c.t2.Contains(key) {
+		return c.t2.Add(key, value)
+	}
+
+	if c.b1.Contains(key) {
+		// 最近淘汰的 key 又被访问, 增大 t1
+		delta := 1
+		if b1, b2 := c.b1.Len(), c.b2.Len(); b2 > b1 {
+			delta = b2 / b1
+		}
+		c.p = min(c.p+delta, c.size)
+		if c.t1.Len()+c.t2.Len() >= c.size {
+			c.replace(false)
+		}
+		_ = c.b1.Remove(key)
+		return c.t2.Add(key, value)
+	}
+	if c.b2.Contains(key) {
+		// 频繁访问的 key 被淘汰之后又被访问, 减小 t1
+		delta := 1
+		if b1, b2 := c.b1.Len(), c.b2.Len(); b1 > b2 {
+			delta = b1 / b2
+		}
+		c.p = max(c.p-delta, 0)
+		if c.t1.Len()+c.t2.Len() >= c.size {
+			c.replace(true)
+		}
+		_ = c.b2.Remove(key)
+		return c.t2.Add(key, value)
+	}
+
+	if c.t1.Len()+c.t2.Len() >= c.size {
+		c.replace(false)
+	}
+	if c.b1.Len() > c.size-c.p {
+		c.b1.RemoveOldest()
+	}
+	if c.b2.Len() > c.p {
+		c.b2.RemoveOldest()
+	}
+	return c.t1.Add(key, value)
+}
+
+// replace 按 p 从 t1 或者 t2 淘汰一个 key 并记录到对应的 ghost 队列.
+func (c *ARC[K, V]) replace(b2ContainsKey bool) {
+	t1Len := c.t1.Len()
+	if t1Len > 0 && (t1Len > c.p || (t1Len == c.p && b2ContainsKey)) {
+		if key, _, ok := c.t1.RemoveOldest(); ok {
+			_ = c.b1.Add(key, struct{}{})
+		}
+		return
+	}
+	if key, _, ok := c.t2.RemoveOldest(); ok {
+		_ = c.b2.Add(key, struct{}{})
+		return
+	}
+	if key, _, ok := c.t1.RemoveOldest(); ok {
+		_ = c.b1.Add(key, struct{}{})
+	}
+}
+
+func (c *ARC[K, V]) Remove(key K) error {
+	_ = c.t1.Remove(key)
+	_ = c.t2.Remove(key)
+	_ = c.b1.Remove(key)
+	return c.b2.Remove(key)
+}
+
+func (c *ARC[K, V]) Len() int {
+	return c.t1.Len() + c.t2.Len()
+}
@@ -1,9 +1,19 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"sync/atomic"
+	"time"
 )
 
+// 并发安全的基本类型, 方法和 sync/atomic 对应, 额外支持 String 和 json 编解码, 可以直接作为配置或者统计数据的字段.
+// MarshalJSON 是指针方法, 作为值字段时需要通过指针编码所在的结构体, 例如 json.Marshal(&stats);
+// 按值编码时 encoding/json 无法调用这些方法, Bool 输出为数字, 其他类型输出为 {}.
+
 type Bool uint32 // zero value represents false
 
 func (b *Bool) Load() (val bool) {
@@ -36,3 +46,340 @@ func (b *Bool) CompareAndSwap(old, new bool) (swapped bool) {
 	}
 	return atomic.CompareAndSwapUint32((*uint32)(b), _old, _new)
 }
+
+func (b *Bool) String() string {
+	return strconv.FormatBool(b.Load())
+}
+
+func (b *Bool) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Load())
+}
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	b.Store(v)
+	return nil
+}
+
+// Int32 是并发安全的 int32, 零值为 0, 使用之后不能复制.
+type Int32 struct {
+	v atomic.Int32
+}
+
+// NewInt32 创建初始值为 val 的 Int32.
+func NewInt32(val int32) *Int32 {
+	i := &Int32{}
+	i.Store(val)
+	return i
+}
+
+func (i *Int32) Load() int32                        { return i.v.Load() }
+func (i *Int32) Store(val int32)                    { i.v.Store(val) }
+func (i *Int32) Swap(new int32) (old int32)         { return i.v.Swap(new) }
+func (i *Int32) CompareAndSwap(old, new int32) bool { return i.v.CompareAndSwap(old, new) }
+func (i *Int32) Add(delta int32) (new int32)        { return i.v.Add(delta) }
+func (i *Int32) Inc() (new int32)                   { return i.v.Add(1) }
+func (i *Int32) Dec() (new int32)                   { return i.v.Add(-1) }
+func (i *Int32) String() string                     { return strconv.FormatInt(int64(i.Load()), 10) }
+func (i *Int32) MarshalJSON() ([]byte, error)       { return json.Marshal(i.Load()) }
+func (i *Int32) UnmarshalJSON(data []byte) error    { return unmarshalInto(data, i.Store) }
+
+// Int64 是并发安全的 int64, 零值为 0, 32 位平台上也保证对齐, 使用之后不能复制.
+type Int64 struct {
+	v atomic.Int64
+}
+
+// NewInt64 创建初始值为 val 的 Int64.
+func NewInt64(val int64) *Int64 {
+	i := &Int64{}
+	i.Store(val)
+	return i
+}
+
+func (i *Int64) Load() int64                        { return i.v.Load() }
+func (i *Int64) Store(val int64)                    { i.v.Store(val) }
+func (i *Int64) Swap(new int64) (old int64)         { return i.v.Swap(new) }
+func (i *Int64) CompareAndSwap(old, new int64) bool { return i.v.CompareAndSwap(old, new) }
+func (i *Int64) Add(delta int64) (new int64)        { return i.v.Add(delta) }
+func (i *Int64) Inc() (new int64)                   { return i.v.Add(1) }
+func (i *Int64) Dec() (new int64)                   { return i.v.Add(-1) }
+func (i *Int64) String() string                     { return strconv.FormatInt(i.Load(), 10) }
+func (i *Int64) MarshalJSON() ([]byte, error)       { return json.Marshal(i.Load()) }
+func (i *Int64) UnmarshalJSON(data []byte) error    { return unmarshalInto(data, i.Store) }
+
+// Uint64 是并发安全的 uint64, 零值为 0, 32 位平台上也保证对齐, 使用之后不能复制.
+type Uint64 struct {
+	v atomic.Uint64
+}
+
+// NewUint64 创建初始值为 val 的 Uint64.
+func NewUint64(val uint64) *Uint64 {
+	u := &Uint64{}
+	u.Store(val)
+	return u
+}
+
+func (u *Uint64) Load() uint64                        { return u.v.Load() }
+func (u *Uint64) Store(val uint64)                    { u.v.Store(val) }
+func (u *Uint64) Swap(new uint64) (old uint64)        { return u.v.Swap(new) }
+func (u *Uint64) CompareAndSwap(old, new uint64) bool { return u.v.CompareAndSwap(old, new) }
+func (u *Uint64) Add(delta uint64) (new uint64)       { return u.v.Add(delta) }
+func (u *Uint64) Inc() (new uint64)                   { return u.v.Add(1) }
+
+// Dec 减 1, 为 0 时回绕到最大值.
+func (u *Uint64) Dec() (new uint64)               { return u.v.Add(^uint64(0)) }
+func (u *Uint64) String() string                  { return strconv.FormatUint(u.Load(), 10) }
+func (u *Uint64) MarshalJSON() ([]byte, error)    { return json.Marshal(u.Load()) }
+func (u *Uint64) UnmarshalJSON(data []byte) error { return unmarshalInto(data, u.Store) }
+
+// Duration 是并发安全的 time.Duration, 零值为 0. json 中为 "1.5s" 格式, 解析时也接受纳秒数.
+type Duration struct {
+	v atomic.Int64
+}
+
+// NewDuration 创建初始值为 val 的 Duration.
+func NewDuration(val time.Duration) *Duration {
+	d := &Duration{}
+	d.Store(val)
+	return d
+}
+
+func (d *Duration) Load() time.Duration                  { return time.Duration(d.v.Load()) }
+func (d *Duration) Store(val time.Duration)              { d.v.Store(int64(val)) }
+func (d *Duration) Swap(new time.Duration) time.Duration { return time.Duration(d.v.Swap(int64(new))) }
+func (d *Duration) Add(delta time.Duration) time.Duration {
+	return time.Duration(d.v.Add(int64(delta)))
+}
+func (d *Duration) CompareAndSwap(old, new time.Duration) bool {
+	return d.v.CompareAndSwap(int64(old), int64(new))
+}
+func (d *Duration) String() string               { return d.Load().String() }
+func (d *Duration) MarshalJSON() ([]byte, error) { return json.Marshal(d.Load().String()) }
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		d.Store(time.Duration(v))
+	case string:
+		val, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		d.Store(val)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// Float64 是并发安全的 float64, 零值为 0. CompareAndSwap 按位比较, 所以 NaN 可以和自己交换, 0 和 -0 不相等.
+type Float64 struct {
+	v atomic.Uint64
+}
+
+// NewFloat64 创建初始值为 val 的 Float64.
+func NewFloat64(val float64) *Float64 {
+	f := &Float64{}
+	f.Store(val)
+	return f
+}
+
+func (f *Float64) Load() float64     { return math.Float64frombits(f.v.Load()) }
+func (f *Float64) Store(val float64) { f.v.Store(math.Float64bits(val)) }
+func (f *Float64) Swap(new float64) float64 {
+	return math.Float64frombits(f.v.Swap(math.Float64bits(new)))
+}
+func (f *Float64) CompareAndSwap(old, new float64) bool {
+	return f.v.CompareAndSwap(math.Float64bits(old), math.Float64bits(new))
+}
+
+// Add 通过 CompareAndSwap 循环加上 delta, 返回新的值.
+func (f *Float64) Add(delta float64) (new float64) {
+	for {
+		old := f.v.Load()
+		new = math.Float64frombits(old) + delta
+		if f.v.CompareAndSwap(old, math.Float64bits(new)) {
+			return new
+		}
+	}
+}
+
+func (f *Float64) String() string                  { return strconv.FormatFloat(f.Load(), 'g', -1, 64) }
+func (f *Float64) MarshalJSON() ([]byte, error)    { return json.Marshal(f.Load()) }
+func (f *Float64) UnmarshalJSON(data []byte) error { return unmarshalInto(data, f.Store) }
+
+// String 是并发安全的 string, 零值为 "".
+type String struct {
+	v Value[string]
+}
+
+// NewString 创建初始值为 val 的 String.
+func NewString(val string) *String {
+	s := &String{}
+	s.Store(val)
+	return s
+}
+
+func (s *String) Load() string                 { return s.v.Load() }
+func (s *String) Store(val string)             { s.v.Store(val) }
+func (s *String) Swap(new string) (old string) { return s.v.Swap(new) }
+
+// CompareAndSwap 在当前值等于 old 时替换为 new, 零值时当前值为 "".
+func (s *String) CompareAndSwap(old, new string) bool {
+	return s.v.compareAndSwap(new, func(cur string) bool { return cur == old })
+}
+
+func (s *String) String() string                  { return s.Load() }
+func (s *String) MarshalJSON() ([]byte, error)    { return json.Marshal(s.Load()) }
+func (s *String) UnmarshalJSON(data []byte) error { return unmarshalInto(data, s.Store) }
+
+// Error 是并发安全的 error, 零值为 nil, 可以存储 nil 和不同类型的 error.
+type Error struct {
+	v Value[error]
+}
+
+// NewError 创建初始值为 err 的 Error.
+func NewError(err error) *Error {
+	e := &Error{}
+	e.Store(err)
+	return e
+}
+
+func (e *Error) Load() error                { return e.v.Load() }
+func (e *Error) Store(err error)            { e.v.Store(err) }
+func (e *Error) Swap(new error) (old error) { return e.v.Swap(new) }
+
+// CompareAndSwap 在当前的 error 和 old 相同 (==) 时替换为 new, 常用 CompareAndSwap(nil, err) 只记录第一个错误.
+// 和 == 一样, 当前值和 old 是相同的不可比较类型时会 panic.
+func (e *Error) CompareAndSwap(old, new error) bool {
+	return e.v.compareAndSwap(new, func(cur error) bool { return cur == old })
+}
+
+// String 返回 error 的信息, nil 时为 "<nil>".
+func (e *Error) String() string {
+	if err := e.Load(); err != nil {
+		return err.Error()
+	}
+	return "<nil>"
+}
+
+// MarshalJSON 把 error 编码为错误信息, nil 时为 null.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	if err := e.Load(); err != nil {
+		return json.Marshal(err.Error())
+	}
+	return []byte("null"), nil
+}
+
+// UnmarshalJSON 把错误信息解码为 errors.New 创建的 error, 原来的类型无法恢复.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var msg *string
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg == nil {
+		e.Store(nil)
+	} else {
+		e.Store(errors.New(*msg))
+	}
+	return nil
+}
+
+// Value 是并发安全的任意类型的值, 零值时 Load 返回 T 的零值. 和 atomic.Value 不同, 可以存储 nil 接口和不同的具体类型.
+// 每次 Store 都会分配一个新的 T, 存储的值应该当作不可变的.
+type Value[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// NewValue 创建初始值为 val 的 Value.
+func NewValue[T any](val T) *Value[T] {
+	v := &Value[T]{}
+	v.Store(val)
+	return v
+}
+
+func (v *Value[T]) Load() T {
+	if p := v.p.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+func (v *Value[T]) Store(val T) { v.p.Store(&val) }
+
+func (v *Value[T]) Swap(new T) (old T) {
+	if p := v.p.Swap(&new); p != nil {
+		return *p
+	}
+	return old
+}
+
+// compareAndSwap 在 match(当前值) 为 true 时替换为 new, 当前值在检查期间被修改时重试.
+func (v *Value[T]) compareAndSwap(new T, match func(cur T) bool) bool {
+	for {
+		p := v.p.Load()
+		var cur T
+		if p != nil {
+			cur = *p
+		}
+		if !match(cur) {
+			return false
+		}
+		if v.p.CompareAndSwap(p, &new) {
+			return true
+		}
+	}
+}
+
+func (v *Value[T]) String() string                  { return fmt.Sprint(v.Load()) }
+func (v *Value[T]) MarshalJSON() ([]byte, error)    { return json.Marshal(v.Load()) }
+func (v *Value[T]) UnmarshalJSON(data []byte) error { return unmarshalInto(data, v.Store) }
+
+// Pointer 是并发安全的 *T, 零值为 nil, 常用于整体替换的配置. 指向的值不能修改, 修改时创建新的值再 Store.
+type Pointer[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// NewPointer 创建初始值为 val 的 Pointer.
+func NewPointer[T any](val *T) *Pointer[T] {
+	p := &Pointer[T]{}
+	p.Store(val)
+	return p
+}
+
+func (p *Pointer[T]) Load() *T                        { return p.p.Load() }
+func (p *Pointer[T]) Store(val *T)                    { p.p.Store(val) }
+func (p *Pointer[T]) Swap(new *T) (old *T)            { return p.p.Swap(new) }
+func (p *Pointer[T]) CompareAndSwap(old, new *T) bool { return p.p.CompareAndSwap(old, new) }
+
+// String 返回指向的值, nil 时为 "<nil>".
+func (p *Pointer[T]) String() string {
+	if val := p.Load(); val != nil {
+		return fmt.Sprint(*val)
+	}
+	return "<nil>"
+}
+
+// MarshalJSON 编码指向的值, nil 时为 null.
+func (p *Pointer[T]) MarshalJSON() ([]byte, error) { return json.Marshal(p.Load()) }
+
+// UnmarshalJSON 解码到新分配的 T 并替换指针, null 时设置为 nil.
+func (p *Pointer[T]) UnmarshalJSON(data []byte) error { return unmarshalInto(data, p.Store) }
+
+// unmarshalInto 把 data 解码为 T 之后调用 store, 解码失败时不修改.
+func unmarshalInto[T any](data []byte, store func(T)) error {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	store(v)
+	return nil
+}
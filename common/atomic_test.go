@@ -1,8 +1,14 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestBool_CompareAndSwap(t *testing.T) {
@@ -138,3 +144,187 @@ func ExampleBool_Swap() {
 	// false
 	// true
 }
+
+// TestAtomic_Race 并发修改所有类型, go test -race 检查没有数据竞争, 同时检查计数没有丢失.
+func TestAtomic_Race(t *testing.T) {
+	const goroutines, n = 8, 1000
+	var (
+		i32 Int32
+		i64 Int64
+		u64 Uint64
+		dur Duration
+		f64 Float64
+		str String
+		e   Error
+		val Value[[]int]
+		ptr Pointer[int]
+		wg  sync.WaitGroup
+	)
+	errFirst := errors.New("first")
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k := 0; k < n; k++ {
+				i32.Inc()
+				i64.Add(2)
+				u64.Inc()
+				dur.Add(time.Millisecond)
+				f64.Add(0.5)
+				str.Store(strconv.Itoa(g))
+				_ = str.Load()
+				e.CompareAndSwap(nil, errFirst)
+				val.Store([]int{g, k})
+				_ = val.Load()
+				v := k
+				ptr.Store(&v)
+				_ = ptr.String()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	const total = goroutines * n
+	if i32.Load() != total || i64.Load() != 2*total || u64.Load() != total {
+		t.Errorf("Int32 = %v, Int64 = %v, Uint64 = %v, want %d", &i32, &i64, &u64, total)
+	}
+	if dur.Load() != total*time.Millisecond || f64.Load() != total/2 {
+		t.Errorf("Duration = %v, Float64 = %v", &dur, &f64)
+	}
+	if e.Load() != errFirst || len(val.Load()) != 2 || ptr.Load() == nil {
+		t.Errorf("Error = %v, Value = %v, Pointer = %v", &e, &val, &ptr)
+	}
+}
+
+func TestAtomic_Methods(t *testing.T) {
+	i := NewInt32(5)
+	if i.Dec() != 4 || i.Swap(10) != 4 || !i.CompareAndSwap(10, 1) || i.CompareAndSwap(10, 2) || i.String() != "1" {
+		t.Errorf("Int32 = %v", i)
+	}
+	i64 := NewInt64(-1)
+	if i64.Inc() != 0 || i64.Dec() != -1 || i64.Swap(3) != -1 || !i64.CompareAndSwap(3, 4) || i64.String() != "4" {
+		t.Errorf("Int64 = %v", i64)
+	}
+	u := NewUint64(0)
+	if u.Dec() != math.MaxUint64 || u.Inc() != 0 || u.Add(7) != 7 || !u.CompareAndSwap(7, 8) || u.String() != "8" {
+		t.Errorf("Uint64 = %v", u)
+	}
+	d := NewDuration(time.Second)
+	if d.Add(500*time.Millisecond) != 1500*time.Millisecond || d.String() != "1.5s" || d.Swap(0) != 1500*time.Millisecond {
+		t.Errorf("Duration = %v", d)
+	}
+	f := NewFloat64(1.5)
+	if f.Add(1) != 2.5 || f.Swap(-0.0) != 2.5 || !f.CompareAndSwap(0, 1) || f.String() != "1" {
+		t.Errorf("Float64 = %v", f)
+	}
+	s := NewString("a")
+	if s.Swap("b") != "a" || s.CompareAndSwap("a", "c") || !s.CompareAndSwap("b", "c") || s.String() != "c" {
+		t.Errorf("String = %v", s)
+	}
+	var zero String
+	if !zero.CompareAndSwap("", "x") || zero.Load() != "x" {
+		t.Errorf("zero String CompareAndSwap failed: %v", &zero)
+	}
+
+	errA, errB := errors.New("a"), errors.New("b")
+	e := NewError(nil)
+	if e.String() != "<nil>" || !e.CompareAndSwap(nil, errA) || e.CompareAndSwap(nil, errB) || e.Load() != errA {
+		t.Errorf("Error = %v", e)
+	}
+	if e.Swap(nil) != errA || e.Load() != nil {
+		t.Errorf("Error after Swap = %v", e)
+	}
+
+	// Value 可以存储 nil 接口和不同的具体类型
+	var v Value[interface{}]
+	v.Store(1)
+	v.Store("one")
+	v.Store(nil)
+	if v.Load() != nil || v.Swap(2) != nil || v.String() != "2" {
+		t.Errorf("Value = %v", &v)
+	}
+
+	one, two := 1, 2
+	p := NewPointer(&one)
+	if p.CompareAndSwap(&two, nil) || !p.CompareAndSwap(&one, &two) || p.String() != "2" || p.Swap(nil) != &two || p.String() != "<nil>" {
+		t.Errorf("Pointer = %v", p)
+	}
+}
+
+func TestAtomic_JSON(t *testing.T) {
+	type config struct {
+		Enabled  *Bool            `json:"enabled"`
+		Count    *Int32           `json:"count"`
+		Total    *Int64           `json:"total"`
+		Sent     *Uint64          `json:"sent"`
+		Timeout  *Duration        `json:"timeout"`
+		Ratio    *Float64         `json:"ratio"`
+		Name     *String          `json:"name"`
+		LastErr  *Error           `json:"last_err"`
+		Tags     *Value[[]string] `json:"tags"`
+		Limit    *Pointer[int]    `json:"limit"`
+		Fallback *Pointer[int]    `json:"fallback"`
+	}
+	limit := 10
+	enabled := Bool(1)
+	in := config{
+		Enabled:  &enabled,
+		Count:    NewInt32(-3),
+		Total:    NewInt64(1 << 40),
+		Sent:     NewUint64(math.MaxUint64),
+		Timeout:  NewDuration(1500 * time.Millisecond),
+		Ratio:    NewFloat64(0.25),
+		Name:     NewString("demo"),
+		LastErr:  NewError(errors.New("broken")),
+		Tags:     NewValue([]string{"a", "b"}),
+		Limit:    NewPointer(&limit),
+		Fallback: NewPointer[int](nil),
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"enabled":true,"count":-3,"total":1099511627776,"sent":18446744073709551615,"timeout":"1.5s","ratio":0.25,` +
+		`"name":"demo","last_err":"broken","tags":["a","b"],"limit":10,"fallback":null}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(out); string(got) != want {
+		t.Errorf("Marshal(Unmarshal()) = %s, want %s", got, want)
+	}
+
+	// 作为值字段时通过指针编码所在的结构体
+	type stats struct {
+		N Int64
+		B Bool
+		D Duration
+	}
+	var st stats
+	st.N.Store(3)
+	st.B.Store(true)
+	st.D.Store(time.Second)
+	if data, err := json.Marshal(&st); err != nil || string(data) != `{"N":3,"B":true,"D":"1s"}` {
+		t.Errorf("Marshal(&stats) = %s, %v", data, err)
+	}
+	var decoded stats
+	if err := json.Unmarshal([]byte(`{"N":5,"B":true,"D":"2s"}`), &decoded); err != nil || decoded.N.Load() != 5 || !decoded.B.Load() || decoded.D.Load() != 2*time.Second {
+		t.Errorf("Unmarshal(&stats) = %v, %v, %v, %v", &decoded.N, &decoded.B, &decoded.D, err)
+	}
+
+	// Duration 也接受纳秒数, 格式错误时不修改原来的值
+	d := NewDuration(time.Second)
+	if err := json.Unmarshal([]byte(`2000`), d); err != nil || d.Load() != 2000 {
+		t.Errorf("Unmarshal(2000) = %v, %v", d, err)
+	}
+	if err := json.Unmarshal([]byte(`"abc"`), d); err == nil || d.Load() != 2000 {
+		t.Errorf("Unmarshal(abc) = %v, %v", d, err)
+	}
+	if err := json.Unmarshal([]byte(`"x"`), NewInt64(0)); err == nil {
+		t.Errorf("Int64 Unmarshal(x) error = nil")
+	}
+}
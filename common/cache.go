@@ -0,0 +1,94 @@
+package common
+
+import (
+	"math/rand"
+)
+
+// Cache 是各种淘汰策略的缓存的公共接口.
+//
+// ⚠️注意: 除了 SyncLRU 以外的实现都不是并发安全的.
+type Cache[K comparable, V any] interface {
+	// Get 返回 key 对应的值, 同时记录一次访问.
+	Get(key K) (V, bool)
+
+	// Add 添加或者覆盖 key, 超过容量时按各自的策略淘汰.
+	Add(key K, value V) error
+
+	// Remove 删除 key, key 不存在时不返回错误.
+	Remove(key K) error
+
+	// Len 返回缓存的 key 个数.
+	Len() int
+}
+
+var (
+	_ Cache[int, int] = (*LRU[int, int])(nil)
+	_ Cache[int, int] = (*SyncLRU[int, int])(nil)
+	_ Cache[int, int] = (*LFU[int, int])(nil)
+	_ Cache[int, int] = (*TwoQueue[int, int])(nil)
+	_ Cache[int, int] = (*ARC[int, int])(nil)
+	_ Cache[int, int] = (*TinyLFU[int, int])(nil)
+)
+
+// ReplayResult 是按访问序列回放的结果.
+type ReplayResult struct {
+	Requests int
+	Hits     int
+}
+
+// HitRatio 返回命中率, 没有请求时为 0.
+func (r ReplayResult) HitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+// Replay 按 trace 的顺序访问 c, 未命中时 Add, 用于比较不同淘汰策略的命中率.
+func Replay[K comparable](c Cache[K, K], trace []K) ReplayResult {
+	result := ReplayResult{Requests: len(trace)}
+	for _, key := range trace {
+		if _, ok := c.Get(key); ok {
+			result.Hits++
+			continue
+		}
+		_ = c.Add(key, key)
+	}
+	return result
+}
+
+// ZipfTrace 生成 n 次访问, key 在 [0, keys) 中服从参数为 s (s > 1) 的 Zipf 分布, 模拟少数热点 key.
+func ZipfTrace(n, keys int, s float64, seed int64) []int {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), s, 1, uint64(keys-1))
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(zipf.Uint64())
+	}
+	return trace
+}
+
+// ScanTrace 在 Zipf 分布的访问中, 每隔 interval 次插入一段 scanLen 个只访问一次的冷 key, 模拟批量扫描冲掉热点.
+func ScanTrace(n, keys int, s float64, interval, scanLen int, seed int64) []int {
+	hot := ZipfTrace(n, keys, s, seed)
+	trace := make([]int, 0, n+n/interval*scanLen)
+	cold := keys
+	for i, key := range hot {
+		if i > 0 && i%interval == 0 {
+			for j := 0; j < scanLen; j++ {
+				trace = append(trace, cold)
+				cold++
+			}
+		}
+		trace = append(trace, key)
+	}
+	return trace
+}
+
+// LoopTrace 生成 n 次访问, 按顺序循环访问 [0, keys), keys 大于容量时 LRU 的命中率为 0.
+func LoopTrace(n, keys int) []int {
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = i % keys
+	}
+	return trace
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StatsReporter 是可以注册到 CacheRegistry 的缓存, LRU 和 SyncLRU 都实现了.
+type StatsReporter interface {
+	Stats() CacheStats
+}
+
+// HotKeysReporter 是可以返回热点 key 的缓存, 注册的缓存实现了该接口时 CacheRegistry.Report 才会返回热点 key.
+type HotKeysReporter interface {
+	HotKeys(n int) []HotKey
+}
+
+// StatsFunc 把函数适配为 StatsReporter.
+type StatsFunc func() CacheStats
+
+func (f StatsFunc) Stats() CacheStats { return f() }
+
+// CacheReport 是一个缓存的统计数据和热点 key.
+type CacheReport struct {
+	Name     string     `json:"name"`
+	Stats    CacheStats `json:"stats"`
+	HitRatio float64    `json:"hit_ratio"`
+	HotKeys  []HotKey   `json:"hot_keys,omitempty"`
+}
+
+// CacheRegistry 按名称记录进程内的缓存, 用于统一查看统计数据, 并发安全.
+type CacheRegistry struct {
+	mu     sync.RWMutex
+	caches map[string]StatsReporter
+}
+
+// NewCacheRegistry 创建空的 CacheRegistry.
+func NewCacheRegistry() *CacheRegistry {
+	return &CacheRegistry{caches: make(map[string]StatsReporter)}
+}
+
+// Register 注册名称为 name 的缓存, 名称不能重复.
+func (r *CacheRegistry) Register(name string, cache StatsReporter) error {
+	if name == "" {
+		return fmt.Errorf("empty cache name")
+	}
+	if cache == nil {
+		return fmt.Errorf("nil cache %s", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.caches[name]; ok {
+		return fmt.Errorf("cache %s already registered", name)
+	}
+	r.caches[name] = cache
+	return nil
+}
+
+// Unregister 删除名称为 name 的缓存, 不存在时什么都不做.
+func (r *CacheRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.caches, name)
+}
+
+// Names 按字典序返回所有缓存的名称.
+func (r *CacheRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Report 按名称的字典序返回所有缓存的统计数据, hotKeys 大于 0 时同时返回每个缓存访问最多的 hotKeys 个 key.
+// 统计热点 key 需要遍历缓存, hotKeys 为 0 时开销很小.
+func (r *CacheRegistry) Report(hotKeys int) []CacheReport {
+	r.mu.RLock()
+	caches := make(map[string]StatsReporter, len(r.caches))
+	for name, cache := range r.caches {
+		caches[name] = cache
+	}
+	r.mu.RUnlock()
+
+	reports := make([]CacheReport, 0, len(caches))
+	for name, cache := range caches {
+		stats := cache.Stats()
+		report := CacheReport{Name: name, Stats: stats, HitRatio: stats.HitRatio()}
+		if hot, ok := cache.(HotKeysReporter); ok && hotKeys > 0 {
+			report.HotKeys = hot.HotKeys(hotKeys)
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
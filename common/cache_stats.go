@@ -0,0 +1,208 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EvictionStats 是按原因统计的移除次数.
+type EvictionStats struct {
+	Capacity uint64 `json:"capacity"` // 超过容量被淘汰
+	Expired  uint64 `json:"expired"`  // 过期
+	Removed  uint64 `json:"removed"`  // 调用 Remove 或者 Purge
+	Replaced uint64 `json:"replaced"` // 被 Add 覆盖
+}
+
+func (e *EvictionStats) record(reason EvictReason) {
+	switch reason {
+	case EvictCapacity:
+		e.Capacity++
+	case EvictExpired:
+		e.Expired++
+	case EvictRemoved:
+		e.Removed++
+	case EvictReplaced:
+		e.Replaced++
+	}
+}
+
+// Total 返回所有原因的移除次数之和.
+func (e EvictionStats) Total() uint64 {
+	return e.Capacity + e.Expired + e.Removed + e.Replaced
+}
+
+// CacheStats 是缓存的统计数据, 计数从创建开始累加, Size 之后的字段是当前的值.
+type CacheStats struct {
+	Hits       uint64        `json:"hits"`       // Get 命中
+	Misses     uint64        `json:"misses"`     // Get 未命中或者已过期
+	Insertions uint64        `json:"insertions"` // 新加入的 key, 覆盖不计算在内
+	Evictions  EvictionStats `json:"evictions"`  // 按原因统计的移除次数
+	Loads      uint64        `json:"loads"`      // GetOrLoad 等读穿透加载的次数, 包括失败的
+	LoadErrors uint64        `json:"load_errors"`
+	LoadTime   time.Duration `json:"load_time_ns"` // 所有加载的总耗时, 除以 Loads 得到平均耗时
+
+	Size    int   `json:"size"`     // 当前 key 的个数, 包括还没有删除的过期 key
+	Cost    int64 `json:"cost"`     // 当前的开销之和
+	Max     int   `json:"max"`      // 容量, 0 表示不限制
+	MaxCost int64 `json:"max_cost"` // 开销上限, 0 表示不限制
+}
+
+// HitRatio 返回命中率, 没有访问时为 0.
+func (s CacheStats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// AvgLoadTime 返回平均加载耗时, 没有加载时为 0.
+func (s CacheStats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// add 把 o 累加到 s, 用于合并多个分片.
+func (s *CacheStats) add(o CacheStats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Insertions += o.Insertions
+	s.Evictions.Capacity += o.Evictions.Capacity
+	s.Evictions.Expired += o.Evictions.Expired
+	s.Evictions.Removed += o.Evictions.Removed
+	s.Evictions.Replaced += o.Evictions.Replaced
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.LoadTime += o.LoadTime
+	s.Size += o.Size
+	s.Cost += o.Cost
+	s.Max += o.Max
+	s.MaxCost += o.MaxCost
+}
+
+// HotKey 是访问最多的 key 之一, Key 是 fmt.Sprint 格式化之后的值.
+type HotKey struct {
+	Key  string `json:"key"`
+	Hits uint64 `json:"hits"`
+}
+
+// Stats 返回统计数据. Peek 和 Contains 不计入命中和未命中.
+func (c *LRU[K, V]) Stats() CacheStats {
+	s := c.stats
+	s.Size = c.Len()
+	s.Cost = c.totalCost
+	s.Max = c.max
+	s.MaxCost = c.maxCost
+	return s
+}
+
+// HotKeys 返回当前缓存中 Get 命中次数最多的 n 个 key, 次数相同时最近访问的在前.
+// 需要遍历所有 key, 只用于排查问题, 不要在请求中调用.
+func (c *LRU[K, V]) HotKeys(n int) []HotKey {
+	return topHotKeys(c.hotNodes(nil), n)
+}
+
+// hotNodes 把命中过的未过期 key 和命中次数复制到 nodes, 复制之后可以在锁外排序.
+func (c *LRU[K, V]) hotNodes(nodes []node[K, V]) []node[K, V] {
+	if c.l == nil {
+		return nodes
+	}
+	for ele := c.l.Front(); ele != nil; ele = ele.Next() {
+		if n := ele.Value.(*node[K, V]); n.hits > 0 && !c.expired(n) {
+			nodes = append(nodes, node[K, V]{key: n.key, hits: n.hits})
+		}
+	}
+	return nodes
+}
+
+func topHotKeys[K comparable, V any](nodes []node[K, V], n int) []HotKey {
+	if n <= 0 || len(nodes) == 0 {
+		return nil
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].hits > nodes[j].hits })
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	keys := make([]HotKey, len(nodes))
+	for i, node := range nodes {
+		keys[i] = HotKey{Key: fmt.Sprint(node.key), Hits: node.hits}
+	}
+	return keys
+}
+
+// GetOrLoad 返回 key 对应的值, 不存在时调用 load 加载并添加. load 返回错误时不添加, 直接返回错误.
+// 加载的次数, 失败次数和耗时记录在 Stats 中.
+func (c *LRU[K, V]) GetOrLoad(key K, load func(key K) (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err := c.load(key, load)
+	if err != nil {
+		return v, err
+	}
+	return v, c.Add(key, v)
+}
+
+// load 调用 load 并记录耗时, 调用方负责并发安全.
+func (c *LRU[K, V]) load(key K, load func(key K) (V, error)) (V, error) {
+	start := c.clock()
+	v, err := load(key)
+	c.recordLoad(c.clock().Sub(start), err)
+	return v, err
+}
+
+func (c *LRU[K, V]) recordLoad(d time.Duration, err error) {
+	c.stats.Loads++
+	c.stats.LoadTime += d
+	if err != nil {
+		c.stats.LoadErrors++
+	}
+}
+
+// Stats 返回所有分片的统计数据之和.
+func (c *SyncLRU[K, V]) Stats() CacheStats {
+	var stats CacheStats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.add(s.lru.Stats())
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// HotKeys 和 LRU.HotKeys 相同, 逐个分片收集之后排序, 不同分片的 key 次数相同时顺序不确定.
+func (c *SyncLRU[K, V]) HotKeys(n int) []HotKey {
+	var nodes []node[K, V]
+	for _, s := range c.shards {
+		s.mu.Lock()
+		nodes = s.lru.hotNodes(nodes)
+		s.mu.Unlock()
+	}
+	return topHotKeys(nodes, n)
+}
+
+// GetOrLoad 和 LRU.GetOrLoad 相同, load 在分片锁之外调用, 不阻塞其他 key 的读写;
+// 相同 key 的并发请求可能各自加载一次, 需要合并时在 load 中使用 singleflight.
+func (c *SyncLRU[K, V]) GetOrLoad(key K, load func(key K) (V, error)) (V, error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	v, ok := s.lru.Get(key)
+	s.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	start := time.Now()
+	v, err := load(key)
+	d := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.recordLoad(d, err)
+	if err != nil {
+		return v, err
+	}
+	return v, s.lru.Add(key, v)
+}
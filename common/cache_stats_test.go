@@ -0,0 +1,157 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU_Stats(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := NewLRUWithConfig(LRUConfig[string, int]{Max: 2})
+	c.now = func() time.Time { return now }
+
+	_ = c.Add("a", 1)
+	_ = c.AddWithTTL("b", 2, time.Second)
+	_ = c.Add("a", 3) // 覆盖
+	c.Get("a")
+	c.Get("missing")
+	c.Peek("a") // 不计入
+	now = now.Add(time.Second)
+	c.Get("b") // 过期
+	_ = c.Add("c", 4)
+	_ = c.Add("d", 5) // 淘汰 a
+	_ = c.Remove("c")
+
+	want := CacheStats{
+		Hits:       1,
+		Misses:     2,
+		Insertions: 4,
+		Evictions:  EvictionStats{Capacity: 1, Expired: 1, Removed: 1, Replaced: 1},
+		Size:       1,
+		Max:        2,
+	}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := want.HitRatio(); got != 1.0/3 {
+		t.Errorf("HitRatio() = %v", got)
+	}
+	if want.Evictions.Total() != 4 || (CacheStats{}).HitRatio() != 0 {
+		t.Errorf("Total() = %d", want.Evictions.Total())
+	}
+}
+
+func TestLRU_HotKeys(t *testing.T) {
+	c := NewLRU[string, int](0, nil)
+	for key, hits := range map[string]int{"a": 1, "b": 3, "c": 2, "d": 0} {
+		_ = c.Add(key, hits)
+		for i := 0; i < hits; i++ {
+			c.Get(key)
+		}
+	}
+	_ = c.Add("c", 20) // 覆盖保留命中次数
+	want := []HotKey{{Key: "b", Hits: 3}, {Key: "c", Hits: 2}}
+	if got := c.HotKeys(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("HotKeys(2) = %v, want %v", got, want)
+	}
+	if got := c.HotKeys(10); len(got) != 3 {
+		t.Errorf("HotKeys(10) = %v, want keys never hit excluded", got)
+	}
+	if got := c.HotKeys(0); got != nil {
+		t.Errorf("HotKeys(0) = %v, want nil", got)
+	}
+}
+
+func TestLRU_GetOrLoad(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := NewLRU[int, string](0, nil)
+	c.now = func() time.Time { return now }
+	errLoad := errors.New("load failed")
+	load := func(key int) (string, error) {
+		now = now.Add(10 * time.Millisecond)
+		if key < 0 {
+			return "", errLoad
+		}
+		return "v", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if v, err := c.GetOrLoad(1, load); err != nil || v != "v" {
+			t.Fatalf("GetOrLoad(1) = %q, %v", v, err)
+		}
+	}
+	if _, err := c.GetOrLoad(-1, load); !errors.Is(err, errLoad) || c.Contains(-1) {
+		t.Errorf("GetOrLoad(-1) error = %v, want errLoad and not cached", err)
+	}
+	stats := c.Stats()
+	if stats.Loads != 2 || stats.LoadErrors != 1 || stats.LoadTime != 20*time.Millisecond || stats.Hits != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if got := stats.AvgLoadTime(); got != 10*time.Millisecond {
+		t.Errorf("AvgLoadTime() = %v", got)
+	}
+}
+
+func TestSyncLRU_Stats(t *testing.T) {
+	c := NewSyncLRU[int, int](1000, 4, nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, _ = c.GetOrLoad(i, func(key int) (int, error) { return key, nil })
+				c.Get(i)
+			}
+			c.HotKeys(5)
+			c.Stats()
+		}()
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 800 || stats.Max != 1000 || stats.Size != 100 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if hot := c.HotKeys(3); len(hot) != 3 || hot[0].Hits < hot[2].Hits {
+		t.Errorf("HotKeys(3) = %v", hot)
+	}
+}
+
+func TestCacheRegistry(t *testing.T) {
+	r := NewCacheRegistry()
+	lru := NewLRU[string, int](10, nil)
+	_ = lru.Add("a", 1)
+	lru.Get("a")
+	if err := r.Register("lru", lru); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("func", StatsFunc(func() CacheStats { return CacheStats{Hits: 1, Misses: 3} })); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("lru", lru); err == nil {
+		t.Errorf("Register() duplicate name error = nil")
+	}
+	if err := r.Register("", lru); err == nil {
+		t.Errorf("Register() empty name error = nil")
+	}
+
+	reports := r.Report(5)
+	if len(reports) != 2 || reports[0].Name != "func" || reports[0].HitRatio != 0.25 || reports[0].HotKeys != nil {
+		t.Errorf("Report()[0] = %+v", reports[0])
+	}
+	if got := reports[1]; got.Name != "lru" || got.Stats.Size != 1 || !reflect.DeepEqual(got.HotKeys, []HotKey{{Key: "a", Hits: 1}}) {
+		t.Errorf("Report()[1] = %+v", got)
+	}
+	if got := r.Report(0); got[1].HotKeys != nil {
+		t.Errorf("Report(0) hot keys = %v, want nil", got[1].HotKeys)
+	}
+
+	r.Unregister("func")
+	if got := r.Names(); !reflect.DeepEqual(got, []string{"lru"}) {
+		t.Errorf("Names() = %v", got)
+	}
+}
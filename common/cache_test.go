@@ -0,0 +1,124 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+// cachePolicies 是所有淘汰策略的构造函数, 用于对比测试.
+var cachePolicies = []struct {
+	name string
+	new  func(size int) Cache[int, int]
+}{
+	{name: "LRU", new: func(size int) Cache[int, int] { return NewLRU[int, int](size, nil) }},
+	{name: "LFU", new: func(size int) Cache[int, int] { return NewLFU[int, int](size) }},
+	{name: "2Q", new: func(size int) Cache[int, int] { return NewTwoQueue[int, int](size) }},
+	{name: "ARC", new: func(size int) Cache[int, int] { return NewARC[int, int](size) }},
+	{name: "TinyLFU", new: func(size int) Cache[int, int] { return NewTinyLFU[int, int](size) }},
+}
+
+// cacheTraceSize 是对比命中率时缓存的容量.
+const cacheTraceSize = 500
+
+func TestCache(t *testing.T) {
+	for _, policy := range cachePolicies {
+		t.Run(policy.name, func(t *testing.T) {
+			c := policy.new(100)
+			for i := 0; i < 1000; i++ {
+				if err := c.Add(i, i*10); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+				// 访问两次, 防止被准入策略直接丢弃
+				c.Get(i)
+				c.Get(i)
+			}
+			if n := c.Len(); n > 100 {
+				t.Errorf("Len() = %d, want <= 100", n)
+			}
+
+			_ = c.Add(5000, 1)
+			if v, ok := c.Get(5000); !ok || v != 1 {
+				t.Errorf("Get() after Add = %v, %v", v, ok)
+			}
+			_ = c.Add(5000, 2)
+			if v, _ := c.Get(5000); v != 2 {
+				t.Errorf("Get() after overwrite = %v, want 2", v)
+			}
+			_ = c.Remove(5000)
+			if _, ok := c.Get(5000); ok {
+				t.Errorf("Get() after Remove ok = true")
+			}
+			if err := c.Remove(-1); err != nil {
+				t.Errorf("Remove() missing key error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLFU_Evict(t *testing.T) {
+	c := NewLFU[string, int](2)
+	_ = c.Add("a", 1)
+	_ = c.Add("b", 2)
+	c.Get("a")
+	_ = c.Add("c", 3) // b 访问次数最少
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("b should be evicted")
+	}
+	c.Get("c")
+	_ = c.Add("d", 4) // a 和 c 次数相同, a 更久没有访问
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("a should be evicted")
+	}
+}
+
+// TestCache_ScanResistance 检查扫描时 2Q, ARC 和 TinyLFU 的命中率高于 LRU.
+func TestCache_ScanResistance(t *testing.T) {
+	trace := ScanTrace(50000, 10000, 1.1, 1000, 2000, 1)
+	lru := Replay(NewLRU[int, int](cacheTraceSize, nil), trace).HitRatio()
+	for _, policy := range cachePolicies {
+		if policy.name == "LRU" || policy.name == "LFU" {
+			continue
+		}
+		if got := Replay(policy.new(cacheTraceSize), trace).HitRatio(); got <= lru {
+			t.Errorf("%s hit ratio on scan trace = %.3f, want > LRU %.3f", policy.name, got, lru)
+		}
+	}
+}
+
+func TestTraces(t *testing.T) {
+	if got := LoopTrace(5, 3); fmt.Sprint(got) != "[0 1 2 0 1]" {
+		t.Errorf("LoopTrace() = %v", got)
+	}
+	zipf := ZipfTrace(1000, 100, 1.1, 1)
+	if fmt.Sprint(zipf) != fmt.Sprint(ZipfTrace(1000, 100, 1.1, 1)) {
+		t.Errorf("ZipfTrace() is not deterministic")
+	}
+	scan := ScanTrace(1000, 100, 1.1, 100, 10, 1)
+	if len(scan) != 1000+9*10 || scan[100] != 100 || scan[109] != 109 {
+		t.Errorf("ScanTrace() len = %d, scan = %v", len(scan), scan[100:110])
+	}
+}
+
+// BenchmarkCache_HitRatio 回放各个访问序列并报告命中率, go test -run xxx -bench HitRatio ./common 查看.
+func BenchmarkCache_HitRatio(b *testing.B) {
+	traces := []struct {
+		name  string
+		trace []int
+	}{
+		{name: "zipf", trace: ZipfTrace(200000, 10000, 1.1, 1)},
+		{name: "scan", trace: ScanTrace(200000, 10000, 1.1, 1000, 2000, 1)},
+		{name: "loop", trace: LoopTrace(200000, 600)},
+	}
+	for _, tr := range traces {
+		for _, policy := range cachePolicies {
+			b.Run(tr.name+"/"+policy.name, func(b *testing.B) {
+				var result ReplayResult
+				for i := 0; i < b.N; i++ {
+					result = Replay(policy.new(cacheTraceSize), tr.trace)
+				}
+				b.ReportMetric(result.HitRatio()*100, "hit%")
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(tr.trace)), "ns/req")
+			})
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"container/heap"
+)
+
+// LFU 是最不经常使用淘汰的缓存, 超过 max 个时淘汰访问次数最少的 key, 次数相同时淘汰最久没有访问的.
+//
+// 访问次数不会衰减, 以前的热点 key 会一直留在缓存中, 访问分布变化较大时使用 TinyLFU.
+type LFU[K comparable, V any] struct {
+	max   int
+	items map[K]*lfuItem[K, V]
+	heap  lfuHeap[K, V]
+	tick  uint64
+}
+
+type lfuItem[K comparable, V any] struct {
+	key   K
+	value V
+	freq  uint64
+	tick  uint64 // 最后一次访问的时间
+	index int    // 在 heap 中的位置
+}
+
+// NewLFU 创建容量为 max 的 LFU, max 为 0 时不限制.
+func NewLFU[K comparable, V any](max int) *LFU[K, V] {
+	return &LFU[K, V]{max: max, items: make(map[K]*lfuItem[K, V])}
+}
+
+func (c *LFU[K, V]) Get(key K) (V, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.touch(item)
+	return item.value, true
+}
+
+func (c *LFU[K, V]) Add(key K, value V) error {
+	if item, ok := c.items[key]; ok {
+		item.value = value
+		c.touch(item)
+		return nil
+	}
+	if c.max > 0 && len(c.items) >= c.max {
+		oldest := heap.Pop(&c.heap).(*lfuItem[K, V])
+		delete(c.items, oldest.key)
+	}
+	c.tick++
+	item := &lfuItem[K, V]{key: key, value: value, freq: 1, tick: c.tick}
+	c.items[key] = item
+	heap.Push(&c.heap, item)
+	return nil
+}
+
+func (c *LFU[K, V]) Remove(key K) error {
+	if item, ok := c.items[key]; ok {
+		heap.Remove(&c.heap, item.index)
+		delete(c.items, key)
+	}
+	return nil
+}
+
+func (c *LFU[K, V]) Len() int {
+	return len(c.items)
+}
+
+func (c *LFU[K, V]) touch(item *lfuItem[K, V]) {
+	c.tick++
+	item.freq++
+	item.tick = c.tick
+	heap.Fix(&c.heap, item.index)
+}
+
+// lfuHeap 是按 (freq, tick) 排序的最小堆, 堆顶是下一个被淘汰的 key.
+type lfuHeap[K comparable, V any] []*lfuItem[K, V]
+
+func (h lfuHeap[K, V]) Len() int { return len(h) }
+
+func (h lfuHeap[K, V]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K, V]) Push(x interface{}) {
+	item := x.(*lfuItem[K, V])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap[K, V]) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
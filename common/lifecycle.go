@@ -0,0 +1,278 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// State 是组件的生命周期状态, 只能按 New → Starting → Running → Stopping → Stopped 的顺序变化,
+// 启动或者停止失败时进入 Failed. Stopped 和 Failed 是终止状态.
+type State int32
+
+const (
+	StateNew      State = iota // 已创建, 还没有启动
+	StateStarting              // 正在启动
+	StateRunning               // 运行中
+	StateStopping              // 正在停止, Done 已关闭
+	StateStopped               // 已停止
+	StateFailed                // 启动或者停止失败, Err 返回第一个错误
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("State(%d)", int32(s))
+	}
+}
+
+// terminal 返回是否是终止状态.
+func (s State) terminal() bool {
+	return s == StateStopped || s == StateFailed
+}
+
+// ErrInvalidState 表示当前状态不允许该操作, 使用 errors.Is 判断, 具体的状态见 *StateError.
+var ErrInvalidState = errors.New("invalid lifecycle state")
+
+// StateError 是当前状态不允许操作 Op 时返回的错误.
+type StateError struct {
+	Op    string
+	State State
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s in state %s", e.Op, e.State)
+}
+
+func (e *StateError) Is(target error) bool { return target == ErrInvalidState }
+
+// Lifecycle 是组件的生命周期状态机, 所有状态变化都加锁完成, 并发安全, 零值为 StateNew, 可以直接嵌入组件.
+//
+// 典型用法:
+//
+//	if err := c.lifecycle.Start(c.connect); err != nil { ... }  // 启动, 失败时进入 Failed
+//	c.lifecycle.Go(c.loop)                                       // 后台 goroutine, 在 <-c.lifecycle.Done() 时退出
+//	err := c.lifecycle.Stop(ctx, c.release)                      // 停止, 可以重复调用, 返回第一个错误
+type Lifecycle struct {
+	mu       sync.Mutex
+	state    State
+	err      error         // 第一个错误
+	changed  chan struct{} // 状态变化时关闭并置空, 用于 WaitFor
+	done     chan struct{} // 开始停止或者失败时关闭
+	stopping bool          // Stop 已经被调用
+	stopped  chan struct{} // 第一次 Stop 完成时关闭
+	wg       sync.WaitGroup
+}
+
+// State 返回当前状态.
+func (l *Lifecycle) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Err 返回启动或者停止的第一个错误, 没有错误时为 nil.
+func (l *Lifecycle) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Done 返回开始停止或者失败时关闭的 channel, 后台 goroutine 在它关闭时退出.
+func (l *Lifecycle) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.doneChan()
+}
+
+// BeginStart 从 New 进入 Starting, 其他状态返回 *StateError, 保证只启动一次.
+func (l *Lifecycle) BeginStart() error {
+	return l.transition("start", StateNew, StateStarting)
+}
+
+// MarkRunning 从 Starting 进入 Running. 启动期间已经开始停止时返回 *StateError.
+func (l *Lifecycle) MarkRunning() error {
+	return l.transition("mark running", StateStarting, StateRunning)
+}
+
+// Start 进入 Starting 之后调用 start (可以为 nil), 成功时进入 Running, 失败时进入 Failed 并返回错误.
+func (l *Lifecycle) Start(start func() error) error {
+	if err := l.BeginStart(); err != nil {
+		return err
+	}
+	if start != nil {
+		if err := start(); err != nil {
+			l.Fail(err)
+			return err
+		}
+	}
+	return l.MarkRunning()
+}
+
+// Fail 记录 err 并进入 Failed, 已经是终止状态时只记录第一个错误. Fail 不会释放资源, 之后仍然需要调用 Stop.
+func (l *Lifecycle) Fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = err
+	}
+	if !l.state.terminal() {
+		l.setState(StateFailed)
+	}
+}
+
+// Go 在新的 goroutine 中执行 fn, Stop 会等待 fn 返回. 已经开始停止或者失败时不执行, 返回 false.
+func (l *Lifecycle) Go(fn func()) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.state >= StateStopping {
+		return false
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// Wait 等待 Go 启动的 goroutine 全部返回, ctx 结束时返回 ctx.Err().
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop 停止组件: 进入 Stopping 并关闭 Done, 调用 stop (可以为 nil) 释放资源, 等待 Go 启动的 goroutine 返回,
+// 最后进入 Stopped, stop 返回错误或者等待超时时进入 Failed.
+//
+// Stop 可以在任何状态下重复调用, 只有第一次调用会执行 stop, 之后的调用等待第一次完成, 都返回第一个错误.
+// 没有启动过或者已经失败的组件也需要 Stop 释放资源.
+func (l *Lifecycle) Stop(ctx context.Context, stop func(ctx context.Context) error) error {
+	l.mu.Lock()
+	if l.stopping {
+		stopped := l.stopped
+		l.mu.Unlock()
+		select {
+		case <-stopped:
+			return l.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.stopping = true
+	l.stopped = make(chan struct{})
+	if !l.state.terminal() {
+		l.setState(StateStopping)
+	}
+	l.mu.Unlock()
+
+	var err error
+	if stop != nil {
+		err = stop(ctx)
+	}
+	if werr := l.Wait(ctx); err == nil {
+		err = werr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+	switch {
+	case l.state == StateStopping && l.err == nil:
+		l.setState(StateStopped)
+	case !l.state.terminal():
+		l.setState(StateFailed)
+	}
+	close(l.stopped)
+	return l.err
+}
+
+// WaitFor 等待进入 state. 已经不可能进入 state 时 (例如等待 Running 时已经停止) 返回第一个错误,
+// 没有错误时返回 *StateError; ctx 结束时返回 ctx.Err().
+func (l *Lifecycle) WaitFor(ctx context.Context, state State) error {
+	for {
+		l.mu.Lock()
+		cur := l.state
+		if cur == state {
+			l.mu.Unlock()
+			return nil
+		}
+		if cur.terminal() || (state != StateFailed && cur > state) {
+			err := l.err
+			l.mu.Unlock()
+			if err == nil {
+				err = &StateError{Op: "wait for " + state.String(), State: cur}
+			}
+			return err
+		}
+		if l.changed == nil {
+			l.changed = make(chan struct{})
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *Lifecycle) transition(op string, from, to State) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.state != from {
+		return &StateError{Op: op, State: l.state}
+	}
+	l.setState(to)
+	return nil
+}
+
+// setState 修改状态并通知 WaitFor, 调用方持有锁.
+func (l *Lifecycle) setState(state State) {
+	l.state = state
+	if l.changed != nil {
+		close(l.changed)
+		l.changed = nil
+	}
+	if state >= StateStopping {
+		select {
+		case <-l.doneChan():
+		default:
+			close(l.done)
+		}
+	}
+}
+
+// doneChan 返回 done, 零值时创建, 调用方持有锁.
+func (l *Lifecycle) doneChan() chan struct{} {
+	if l.done == nil {
+		l.done = make(chan struct{})
+	}
+	return l.done
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	var l Lifecycle
+	if l.State() != StateNew {
+		t.Fatalf("zero State() = %v, want new", l.State())
+	}
+	if err := l.MarkRunning(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("MarkRunning() before start error = %v", err)
+	}
+	if err := l.Start(nil); err != nil || l.State() != StateRunning {
+		t.Fatalf("Start() = %v, State() = %v", err, l.State())
+	}
+	if err := l.Start(nil); !errors.Is(err, ErrInvalidState) || err.Error() != "cannot start in state running" {
+		t.Errorf("second Start() error = %v", err)
+	}
+
+	// 后台 goroutine 在 Done 关闭时退出, Stop 等待它返回
+	exited := make(chan struct{})
+	if !l.Go(func() {
+		<-l.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(exited)
+	}) {
+		t.Fatal("Go() = false while running")
+	}
+
+	var stops int
+	stop := func(context.Context) error { stops++; return nil }
+	if err := l.Stop(ctx, stop); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Errorf("Stop() returned before goroutine exited")
+	}
+	if err := l.Stop(ctx, stop); err != nil || stops != 1 || l.State() != StateStopped {
+		t.Errorf("second Stop() = %v, stops = %d, State() = %v", err, stops, l.State())
+	}
+	if l.Go(func() {}) {
+		t.Errorf("Go() after Stop = true")
+	}
+	if err := l.Start(nil); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Start() after Stop error = %v", err)
+	}
+}
+
+func TestLifecycle_StartFailed(t *testing.T) {
+	var l Lifecycle
+	errStart := errors.New("dial failed")
+	if err := l.Start(func() error { return errStart }); err != errStart {
+		t.Fatalf("Start() error = %v", err)
+	}
+	select {
+	case <-l.Done():
+	default:
+		t.Errorf("Done() not closed after start failed")
+	}
+
+	// 失败之后仍然需要 Stop 释放资源, 保持 Failed 并返回第一个错误
+	released := false
+	err := l.Stop(context.Background(), func(context.Context) error {
+		released = true
+		return errors.New("close failed")
+	})
+	if err != errStart || !released || l.State() != StateFailed {
+		t.Errorf("Stop() = %v, released = %v, State() = %v", err, released, l.State())
+	}
+}
+
+func TestLifecycle_StopError(t *testing.T) {
+	var l Lifecycle
+	_ = l.Start(nil)
+	errStop := errors.New("flush failed")
+
+	// 并发 Stop 只执行一次, 都返回第一个错误
+	var wg sync.WaitGroup
+	var calls Int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := l.Stop(context.Background(), func(context.Context) error {
+				calls.Inc()
+				time.Sleep(5 * time.Millisecond)
+				return errStop
+			})
+			if err != errStop {
+				t.Errorf("Stop() error = %v, want %v", err, errStop)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 || l.State() != StateFailed || l.Err() != errStop {
+		t.Errorf("calls = %v, State() = %v, Err() = %v", &calls, l.State(), l.Err())
+	}
+}
+
+func TestLifecycle_StopTimeout(t *testing.T) {
+	var l Lifecycle
+	_ = l.Start(nil)
+	block := make(chan struct{})
+	defer close(block)
+	l.Go(func() { <-block })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Stop(ctx, nil); !errors.Is(err, context.DeadlineExceeded) || l.State() != StateFailed {
+		t.Errorf("Stop() = %v, State() = %v", err, l.State())
+	}
+}
+
+func TestLifecycle_StopWhileStarting(t *testing.T) {
+	var l Lifecycle
+	if err := l.BeginStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.MarkRunning(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("MarkRunning() after Stop error = %v", err)
+	}
+}
+
+func TestLifecycle_WaitFor(t *testing.T) {
+	ctx := context.Background()
+	var l Lifecycle
+	errc := make(chan error, 1)
+	go func() { errc <- l.WaitFor(ctx, StateRunning) }()
+	_ = l.BeginStart()
+	_ = l.MarkRunning()
+	if err := <-errc; err != nil {
+		t.Errorf("WaitFor(running) error = %v", err)
+	}
+
+	go func() { errc <- l.WaitFor(ctx, StateStopped) }()
+	_ = l.Stop(ctx, nil)
+	if err := <-errc; err != nil {
+		t.Errorf("WaitFor(stopped) error = %v", err)
+	}
+
+	// 已经停止, 不可能再进入 Running
+	if err := l.WaitFor(ctx, StateRunning); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("WaitFor(running) after stop error = %v", err)
+	}
+	var idle Lifecycle
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := idle.WaitFor(timeout, StateRunning); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitFor() timeout error = %v", err)
+	}
+}
+
+func TestState_String(t *testing.T) {
+	for state, want := range map[State]string{StateNew: "new", StateRunning: "running", StateFailed: "failed", State(9): "State(9)"} {
+		if got := state.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
@@ -3,83 +3,314 @@ package common
 import (
 	"container/list"
 	"errors"
+	"time"
 )
 
-type LRUCache struct {
+// EvictReason 是 key 从 LRU 中移除的原因.
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota + 1 // 超过容量被淘汰
+	EvictExpired                         // 过期
+	EvictRemoved                         // 调用 Remove 或者 Purge
+	EvictReplaced                        // Add 相同的 key 覆盖了旧的值
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// LRUConfig 是 LRU 的配置, 所有字段都是可选的.
+type LRUConfig[K comparable, V any] struct {
+	Max     int                                      // 最多的 key 个数, 0 表示不限制
+	MaxCost int64                                    // 所有 value 的 Cost 之和的上限, 0 表示不限制
+	Cost    func(key K, value V) int64               // 计算 value 的开销, 例如字节数, MaxCost 大于 0 且 Cost 为 nil 时每个 key 为 1
+	TTL     time.Duration                            // Add 的默认过期时间, 0 表示不过期
+	OnEvict func(key K, value V, reason EvictReason) // key 被移除或者覆盖时调用
+
+	KeyCodec   Codec[K] // Snapshot 和 Restore 编码 key, 默认 JSONCodec
+	ValueCodec Codec[V] // Snapshot 和 Restore 编码 value, 默认 JSONCodec
+}
+
+// LRU 是最近最少使用淘汰的缓存, 超过 max 个或者超过 maxCost 时淘汰最久没有访问的 key, max 为 0 时不限制.
+//
+// 过期的 key 在 Get 时删除, 或者由 RemoveExpired 批量删除.
+//
+// ⚠️注意: LRU 不是并发安全的, Get 也会修改内部状态, 多个 goroutine 访问时使用 SyncLRU.
+type LRU[K comparable, V any] struct {
 	max   int
-	cache map[interface{}]*list.Element
-	Call  func(key interface{}, value interface{})
+	cache map[K]*list.Element
+	Call  func(key K, value V) // 淘汰或者删除时调用, 覆盖时不调用
 	l     *list.List
+
+	maxCost   int64
+	cost      func(key K, value V) int64
+	totalCost int64
+	ttl       time.Duration
+	onEvict   func(key K, value V, reason EvictReason)
+	now       func() time.Time // 为 nil 时使用 time.Now
+
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
+
+	stats CacheStats // 只记录计数, Size 等在 Stats 中计算
+}
+
+// LRUCache 是 key 和 value 都是 interface{} 的 LRU, 新代码使用 NewLRU 指定类型.
+type LRUCache = LRU[interface{}, interface{}]
+
+type node[K comparable, V any] struct {
+	key     K
+	value   V
+	cost    int64
+	expires time.Time // 零值表示不过期
+	hits    uint64    // Get 命中的次数, 用于 HotKeys
+}
+
+// NewLRU 创建容量为 max 的 LRU, call 在淘汰或者删除时调用, 可以为 nil.
+func NewLRU[K comparable, V any](max int, call func(key K, value V)) *LRU[K, V] {
+	return &LRU[K, V]{max: max, cache: make(map[K]*list.Element), Call: call, l: list.New()}
 }
-type node struct {
-	key   interface{}
-	value interface{}
+
+// NewLRUWithConfig 按 config 创建 LRU, 支持过期时间和按开销淘汰.
+func NewLRUWithConfig[K comparable, V any](config LRUConfig[K, V]) *LRU[K, V] {
+	c := NewLRU[K, V](config.Max, nil)
+	c.maxCost = config.MaxCost
+	c.cost = config.Cost
+	if c.maxCost > 0 && c.cost == nil {
+		c.cost = func(K, V) int64 { return 1 }
+	}
+	c.ttl = config.TTL
+	c.onEvict = config.OnEvict
+	c.keyCodec = config.KeyCodec
+	c.valueCodec = config.ValueCodec
+	return c
 }
 
 func NewLRUCache(max int, call func(key interface{}, value interface{})) *LRUCache {
-	return &LRUCache{max: max, cache: make(map[interface{}]*list.Element), Call: call, l: list.New()}
+	return NewLRU[interface{}, interface{}](max, call)
 }
 
-func (c *LRUCache) Get(key interface{}) (interface{}, bool) {
+func (c *LRU[K, V]) Get(key K) (V, bool) {
 	if c.cache == nil || c.l == nil {
-		return nil, false
+		var zero V
+		return zero, false
 	}
 	if ele, ok := c.cache[key]; ok {
+		n := ele.Value.(*node[K, V])
+		if c.expired(n) {
+			c.remove(ele, EvictExpired)
+			c.stats.Misses++
+			var zero V
+			return zero, false
+		}
 		c.l.MoveToFront(ele)
-		return ele.Value.(*node).value, true
+		n.hits++
+		c.stats.Hits++
+		return n.value, true
 	}
-	return nil, false
+	c.stats.Misses++
+	var zero V
+	return zero, false
 }
 
-func (c *LRUCache) Add(key interface{}, value interface{}) error {
+// Peek 返回 key 对应的值, 不更新访问顺序, 过期的 key 视为不存在.
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	if ele, ok := c.cache[key]; ok {
+		if n := ele.Value.(*node[K, V]); !c.expired(n) {
+			return n.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Contains 判断 key 是否存在, 不更新访问顺序, 过期的 key 视为不存在.
+func (c *LRU[K, V]) Contains(key K) bool {
+	ele, ok := c.cache[key]
+	return ok && !c.expired(ele.Value.(*node[K, V]))
+}
+
+// Add 添加或者覆盖 key, 使用默认的过期时间.
+func (c *LRU[K, V]) Add(key K, value V) error {
+	return c.AddWithTTL(key, value, c.ttl)
+}
+
+// AddWithTTL 添加或者覆盖 key, ttl 为 0 时不过期.
+// 单个 value 的开销超过 MaxCost 时, 添加之后会被立即淘汰.
+func (c *LRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) error {
 	if c.cache == nil || c.l == nil {
 		return errors.New("not init")
 	}
-	val := &node{
+	val := &node[K, V]{
 		key:   key,
 		value: value,
 	}
+	if c.cost != nil {
+		val.cost = c.cost(key, value)
+	}
+	if ttl > 0 {
+		val.expires = c.clock().Add(ttl)
+	}
 	if ele, ok := c.cache[key]; ok {
+		old := ele.Value.(*node[K, V])
+		val.hits = old.hits
 		ele.Value = val
+		c.totalCost += val.cost - old.cost
 		c.l.MoveToFront(ele)
-		return nil
+		c.stats.Evictions.record(EvictReplaced)
+		if c.onEvict != nil {
+			c.onEvict(old.key, old.value, EvictReplaced)
+		}
+	} else {
+		ele := c.l.PushFront(val)
+		c.cache[key] = ele
+		c.totalCost += val.cost
+		c.stats.Insertions++
 	}
-	ele := c.l.PushFront(val)
-	c.cache[key] = ele
-	if c.max > 0 && c.l.Len() > c.max {
+	for c.overflow() {
 		c.removeOldest()
 	}
 	return nil
 }
 
-func (c *LRUCache) Remove(key interface{}) error {
+// GetOrAdd 存在 key 时返回已有的值并更新访问顺序, loaded 为 true; 否则添加 value 并返回.
+func (c *LRU[K, V]) GetOrAdd(key K, value V) (actual V, loaded bool, err error) {
+	if v, ok := c.Get(key); ok {
+		return v, true, nil
+	}
+	if err := c.Add(key, value); err != nil {
+		return value, false, err
+	}
+	return value, false, nil
+}
+
+func (c *LRU[K, V]) Remove(key K) error {
 	if c.cache == nil || c.l == nil {
 		return errors.New("not init")
 	}
 	if ele, ok := c.cache[key]; ok {
-		c.remove(ele)
+		c.remove(ele, EvictRemoved)
 		return nil
 	}
 	return nil
 }
 
-func (c *LRUCache) Len() int {
+// RemoveOldest 淘汰最久没有访问的 key, 没有 key 时 ok 为 false.
+func (c *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if c.l == nil || c.l.Len() == 0 {
+		return key, value, false
+	}
+	n := c.l.Back().Value.(*node[K, V])
+	c.removeOldest()
+	return n.key, n.value, true
+}
+
+// RemoveExpired 删除所有过期的 key, 返回删除的个数.
+func (c *LRU[K, V]) RemoveExpired() int {
+	if c.l == nil {
+		return 0
+	}
+	removed := 0
+	now := c.clock()
+	for ele := c.l.Front(); ele != nil; {
+		next := ele.Next()
+		if n := ele.Value.(*node[K, V]); !n.expires.IsZero() && !now.Before(n.expires) {
+			c.remove(ele, EvictExpired)
+			removed++
+		}
+		ele = next
+	}
+	return removed
+}
+
+// Len 返回 key 的个数, 包括还没有删除的过期 key.
+func (c *LRU[K, V]) Len() int {
 	if c.l == nil {
 		return 0
 	}
 	return c.l.Len()
 }
 
-func (c *LRUCache) remove(ele *list.Element) {
-	n := ele.Value.(*node)
+// Cost 返回所有 value 的开销之和, 没有设置 Cost 时为 0.
+func (c *LRU[K, V]) Cost() int64 {
+	return c.totalCost
+}
+
+// Keys 按最近访问的顺序返回所有 key, 最近访问的在前.
+func (c *LRU[K, V]) Keys() []K {
+	if c.l == nil {
+		return nil
+	}
+	keys := make([]K, 0, c.l.Len())
+	for ele := c.l.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(*node[K, V]).key)
+	}
+	return keys
+}
+
+// Resize 修改容量, 超出新容量的部分按 LRU 淘汰, 返回淘汰的个数.
+func (c *LRU[K, V]) Resize(max int) int {
+	c.max = max
+	evicted := 0
+	for c.overflow() {
+		c.removeOldest()
+		evicted++
+	}
+	return evicted
+}
+
+// Purge 删除所有 key, 每个 key 都会调用 Call.
+func (c *LRU[K, V]) Purge() {
+	for c.Len() > 0 {
+		c.remove(c.l.Back(), EvictRemoved)
+	}
+}
+
+func (c *LRU[K, V]) overflow() bool {
+	if c.l.Len() == 0 {
+		return false
+	}
+	return (c.max > 0 && c.l.Len() > c.max) || (c.maxCost > 0 && c.totalCost > c.maxCost)
+}
+
+func (c *LRU[K, V]) expired(n *node[K, V]) bool {
+	return !n.expires.IsZero() && !c.clock().Before(n.expires)
+}
+
+func (c *LRU[K, V]) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *LRU[K, V]) remove(ele *list.Element, reason EvictReason) {
+	n := ele.Value.(*node[K, V])
 	c.l.Remove(ele)
 	delete(c.cache, n.key)
+	c.totalCost -= n.cost
+	c.stats.Evictions.record(reason)
 	if c.Call != nil {
 		c.Call(n.key, n.value)
 	}
+	if c.onEvict != nil {
+		c.onEvict(n.key, n.value, reason)
+	}
 }
 
-func (c *LRUCache) removeOldest() {
+func (c *LRU[K, V]) removeOldest() {
 	ele := c.l.Back()
-	c.remove(ele)
+	c.remove(ele, EvictCapacity)
 }
@@ -0,0 +1,268 @@
+package common
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotMagic 和 snapshotVersion 是快照文件的头部, 格式变化时增加版本号.
+const (
+	snapshotMagic   = "LRUS"
+	snapshotVersion = 1
+
+	maxSnapshotItem = 64 << 20 // 单个 key 或者 value 编码之后的上限, 防止损坏的快照申请过大的内存
+)
+
+// ErrInvalidSnapshot 表示快照的头部不正确或者内容被截断.
+var ErrInvalidSnapshot = errors.New("invalid cache snapshot")
+
+// Codec 把缓存的 key 或者 value 编码为字节, 用于 Snapshot 和 Restore.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec 使用 encoding/json 编码, 是默认的 Codec.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// Snapshotter 是可以保存和恢复的缓存, 例如 LRU 和 SyncLRU.
+type Snapshotter interface {
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// Snapshot 按从旧到新的访问顺序把所有未过期的 key 写入 w, 同时保存过期时间.
+func (c *LRU[K, V]) Snapshot(w io.Writer) error {
+	return writeSnapshot(w, c.keyCodecOrDefault(), c.valueCodecOrDefault(), c.snapshotNodes(nil))
+}
+
+// Restore 从 Snapshot 写入的数据中恢复 key, 保持访问顺序和剩余的过期时间, 已经过期的 key 被跳过.
+// 恢复的 key 按 Add 的规则加入, 已有的 key 被覆盖, 超过容量时淘汰最旧的; 返回错误时已经恢复的 key 保留.
+func (c *LRU[K, V]) Restore(r io.Reader) error {
+	if c.cache == nil || c.l == nil {
+		return errors.New("not init")
+	}
+	return readSnapshot(r, c.keyCodecOrDefault(), c.valueCodecOrDefault(), func(key K, value V, expires time.Time) error {
+		ttl, ok := remainingTTL(expires, c.clock())
+		if !ok {
+			return nil
+		}
+		return c.AddWithTTL(key, value, ttl)
+	})
+}
+
+// Snapshot 逐个分片写入所有未过期的 key, 分片内保持访问顺序, 不同分片之间的顺序没有意义.
+// 每个分片只在复制 key 时加锁, 编码和写入不阻塞其他读写.
+func (c *SyncLRU[K, V]) Snapshot(w io.Writer) error {
+	var nodes []*node[K, V]
+	for _, s := range c.shards {
+		s.mu.Lock()
+		nodes = s.lru.snapshotNodes(nodes)
+		s.mu.Unlock()
+	}
+	lru := c.shards[0].lru
+	return writeSnapshot(w, lru.keyCodecOrDefault(), lru.valueCodecOrDefault(), nodes)
+}
+
+// Restore 和 LRU.Restore 相同, 分片数相同时每个分片的访问顺序和保存时一致.
+func (c *SyncLRU[K, V]) Restore(r io.Reader) error {
+	lru := c.shards[0].lru
+	return readSnapshot(r, lru.keyCodecOrDefault(), lru.valueCodecOrDefault(), func(key K, value V, expires time.Time) error {
+		s := c.shard(key)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ttl, ok := remainingTTL(expires, s.lru.clock())
+		if !ok {
+			return nil
+		}
+		return s.lru.AddWithTTL(key, value, ttl)
+	})
+}
+
+// snapshotNodes 按从旧到新的顺序把未过期的 key 追加到 nodes.
+func (c *LRU[K, V]) snapshotNodes(nodes []*node[K, V]) []*node[K, V] {
+	if c.l == nil {
+		return nodes
+	}
+	for ele := c.l.Back(); ele != nil; ele = ele.Prev() {
+		if n := ele.Value.(*node[K, V]); !c.expired(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+func (c *LRU[K, V]) keyCodecOrDefault() Codec[K] {
+	if c.keyCodec != nil {
+		return c.keyCodec
+	}
+	return JSONCodec[K]{}
+}
+
+func (c *LRU[K, V]) valueCodecOrDefault() Codec[V] {
+	if c.valueCodec != nil {
+		return c.valueCodec
+	}
+	return JSONCodec[V]{}
+}
+
+// remainingTTL 返回 expires 距离 now 的时间, 零值表示不过期, 已经过期时 ok 为 false.
+func remainingTTL(expires, now time.Time) (ttl time.Duration, ok bool) {
+	if expires.IsZero() {
+		return 0, true
+	}
+	ttl = expires.Sub(now)
+	return ttl, ttl > 0
+}
+
+// writeSnapshot 写入快照: 头部 "LRUS" + 版本号, uvarint 的 key 个数, 然后每个 key 依次是
+// uvarint 长度 + key, uvarint 长度 + value, varint 的过期时间 (unix 纳秒, 0 表示不过期).
+func writeSnapshot[K comparable, V any](w io.Writer, keys Codec[K], values Codec[V], nodes []*node[K, V]) error {
+	bw := bufio.NewWriter(w)
+	buf := append([]byte(snapshotMagic), snapshotVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(nodes)))
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		key, err := keys.Encode(n.key)
+		if err != nil {
+			return fmt.Errorf("encode key %v: %w", n.key, err)
+		}
+		value, err := values.Encode(n.value)
+		if err != nil {
+			return fmt.Errorf("encode value of key %v: %w", n.key, err)
+		}
+		var expires int64
+		if !n.expires.IsZero() {
+			expires = n.expires.UnixNano()
+		}
+		buf = binary.AppendUvarint(buf[:0], uint64(len(key)))
+		buf = append(buf, key...)
+		buf = binary.AppendUvarint(buf, uint64(len(value)))
+		buf = append(buf, value...)
+		buf = binary.AppendVarint(buf, expires)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readSnapshot 校验头部并按写入顺序对每个 key 调用 fn.
+func readSnapshot[K comparable, V any](r io.Reader, keys Codec[K], values Codec[V], fn func(key K, value V, expires time.Time) error) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("%w: read header: %v", ErrInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: bad magic %q", ErrInvalidSnapshot, header[:len(snapshotMagic)])
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, version)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("%w: read count: %v", ErrInvalidSnapshot, err)
+	}
+	for i := uint64(0); i < count; i++ {
+		keyData, err := readSnapshotItem(br)
+		if err != nil {
+			return err
+		}
+		valueData, err := readSnapshotItem(br)
+		if err != nil {
+			return err
+		}
+		expiresNano, err := binary.ReadVarint(br)
+		if err != nil {
+			return fmt.Errorf("%w: read expires: %v", ErrInvalidSnapshot, err)
+		}
+		key, err := keys.Decode(keyData)
+		if err != nil {
+			return fmt.Errorf("decode key: %w", err)
+		}
+		value, err := values.Decode(valueData)
+		if err != nil {
+			return fmt.Errorf("decode value of key %v: %w", key, err)
+		}
+		var expires time.Time
+		if expiresNano != 0 {
+			expires = time.Unix(0, expiresNano)
+		}
+		if err := fn(key, value, expires); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSnapshotItem(br *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read length: %v", ErrInvalidSnapshot, err)
+	}
+	if size > maxSnapshotItem {
+		return nil, fmt.Errorf("%w: item too large (%d bytes)", ErrInvalidSnapshot, size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, fmt.Errorf("%w: read item: %v", ErrInvalidSnapshot, err)
+	}
+	return data, nil
+}
+
+// SaveSnapshotFile 把缓存保存到 path. 先写入同目录的临时文件再重命名, 保存失败不会破坏已有的快照.
+func SaveSnapshotFile(path string, s Snapshotter) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err = s.Snapshot(f); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshotFile 从 path 恢复缓存, 文件不存在时什么都不做, 返回 nil.
+func LoadSnapshotFile(path string, s Snapshotter) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Restore(f)
+}
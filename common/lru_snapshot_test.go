@@ -0,0 +1,157 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLRU_SnapshotRestore(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	src := NewLRUWithConfig(LRUConfig[string, int]{Max: 10})
+	src.now = func() time.Time { return now }
+	_ = src.Add("a", 1)
+	_ = src.AddWithTTL("b", 2, time.Minute)
+	_ = src.AddWithTTL("c", 3, time.Second)
+	_ = src.Add("d", 4)
+	src.Get("a")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复时 c 已经过期, b 还剩 50s
+	now = now.Add(10 * time.Second)
+	dst := NewLRUWithConfig(LRUConfig[string, int]{Max: 10})
+	dst.now = func() time.Time { return now }
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dst.Keys(), []string{"a", "d", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	now = now.Add(49 * time.Second)
+	if v, ok := dst.Peek("b"); !ok || v != 2 {
+		t.Errorf("Peek(b) = %v, %v before ttl", v, ok)
+	}
+	now = now.Add(time.Second)
+	if dst.Contains("b") || !dst.Contains("a") {
+		t.Errorf("b should expire with the remaining ttl, a should never expire")
+	}
+}
+
+func TestLRU_RestoreOverflow(t *testing.T) {
+	src := NewLRU[int, int](0, nil)
+	for i := 0; i < 5; i++ {
+		_ = src.Add(i, i)
+	}
+	var buf bytes.Buffer
+	_ = src.Snapshot(&buf)
+
+	// 容量不足时保留最近访问的 key
+	dst := NewLRU[int, int](2, nil)
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dst.Keys(), []int{4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+}
+
+// upperCodec 把 string 编码为大写, 解码时转为小写, 用于检查自定义 Codec 被使用.
+type upperCodec struct{}
+
+func (upperCodec) Encode(v string) ([]byte, error) {
+	return bytes.ToUpper([]byte(v)), nil
+}
+
+func (upperCodec) Decode(data []byte) (string, error) {
+	return string(bytes.ToLower(data)), nil
+}
+
+func TestLRU_SnapshotCodec(t *testing.T) {
+	config := LRUConfig[string, string]{KeyCodec: upperCodec{}, ValueCodec: upperCodec{}}
+	src := NewLRUWithConfig(config)
+	_ = src.Add("key", "value")
+	var buf bytes.Buffer
+	_ = src.Snapshot(&buf)
+	if !bytes.Contains(buf.Bytes(), []byte("KEY")) || !bytes.Contains(buf.Bytes(), []byte("VALUE")) {
+		t.Errorf("snapshot = %q, want encoded by codec", buf.Bytes())
+	}
+	dst := NewLRUWithConfig(config)
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := dst.Get("key"); v != "value" {
+		t.Errorf("Get(key) = %q, want value", v)
+	}
+}
+
+func TestLRU_RestoreInvalid(t *testing.T) {
+	var valid bytes.Buffer
+	src := NewLRU[string, int](0, nil)
+	_ = src.Add("a", 1)
+	_ = src.Snapshot(&valid)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "magic", data: []byte("JSON\x01\x00")},
+		{name: "version", data: []byte("LRUS\x02\x00")},
+		{name: "truncated", data: valid.Bytes()[:valid.Len()-2]},
+		{name: "too-large", data: []byte("LRUS\x01\x01\xff\xff\xff\xff\x0f")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewLRU[string, int](0, nil).Restore(bytes.NewReader(tt.data))
+			if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("Restore() error = %v, want ErrInvalidSnapshot", err)
+			}
+		})
+	}
+
+	// value 类型不匹配时返回解码错误
+	if err := NewLRU[string, bool](0, nil).Restore(bytes.NewReader(valid.Bytes())); err == nil {
+		t.Errorf("Restore() with wrong value type error = nil")
+	}
+}
+
+func TestSyncLRU_SnapshotRestore(t *testing.T) {
+	src := NewSyncLRU[string, int](0, 4, nil)
+	for i := 0; i < 100; i++ {
+		_ = src.Add(strconv.Itoa(i), i)
+	}
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := SaveSnapshotFile(path, src); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewSyncLRU[string, int](0, 4, nil)
+	if err := LoadSnapshotFile(path, dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Len() != 100 {
+		t.Errorf("Len() = %d, want 100", dst.Len())
+	}
+	for i, s := range src.shards {
+		if got, want := dst.shards[i].lru.Keys(), s.lru.Keys(); !reflect.DeepEqual(got, want) {
+			t.Errorf("shard %d Keys() = %v, want %v", i, got, want)
+		}
+	}
+
+	// 文件不存在时不恢复也不报错, 临时文件不会残留
+	if err := LoadSnapshotFile(path+".missing", dst); err != nil {
+		t.Errorf("LoadSnapshotFile() missing file error = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("snapshot dir has %d entries, want 1", len(entries))
+	}
+}
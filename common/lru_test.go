@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLRUCache_Add(t *testing.T) {
@@ -139,3 +140,142 @@ func TestLRUCache_Remove(t *testing.T) {
 		})
 	}
 }
+
+func TestLRU(t *testing.T) {
+	var evicted []string
+	c := NewLRU[string, int](3, func(key string, value int) { evicted = append(evicted, key) })
+	for i, key := range []string{"a", "b", "c"} {
+		_ = c.Add(key, i)
+	}
+
+	// Peek 和 Contains 不更新访问顺序
+	if v, ok := c.Peek("a"); !ok || v != 0 {
+		t.Errorf("Peek(a) = %v, %v", v, ok)
+	}
+	if !c.Contains("b") || c.Contains("z") {
+		t.Errorf("Contains() is wrong")
+	}
+	if got, want := c.Keys(), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+
+	if v, ok := c.Get("a"); !ok || v != 0 {
+		t.Errorf("Get(a) = %v, %v", v, ok)
+	}
+	_ = c.Add("d", 3)
+	if got, want := c.Keys(), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() after Add(d) = %v, want %v", got, want)
+	}
+
+	if v, loaded, _ := c.GetOrAdd("a", 100); !loaded || v != 0 {
+		t.Errorf("GetOrAdd(a) = %v, %v", v, loaded)
+	}
+	if v, loaded, _ := c.GetOrAdd("e", 4); loaded || v != 4 {
+		t.Errorf("GetOrAdd(e) = %v, %v", v, loaded)
+	}
+
+	if n := c.Resize(1); n != 2 || c.Len() != 1 {
+		t.Errorf("Resize(1) = %d, Len() = %d", n, c.Len())
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len() after Purge = %d", c.Len())
+	}
+	if want := []string{"b", "c", "d", "a", "e"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted = %v, want %v", evicted, want)
+	}
+}
+
+func TestLRU_ZeroValue(t *testing.T) {
+	var c LRU[string, int]
+	if v, ok := c.Get("a"); ok || v != 0 {
+		t.Errorf("Get() = %v, %v", v, ok)
+	}
+	if err := c.Add("a", 1); err == nil {
+		t.Errorf("Add() error = nil, want not init")
+	}
+	if c.Keys() != nil || c.Len() != 0 {
+		t.Errorf("Keys() = %v, Len() = %d", c.Keys(), c.Len())
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var reasons []string
+	c := NewLRUWithConfig(LRUConfig[string, int]{
+		TTL:     time.Minute,
+		OnEvict: func(key string, value int, reason EvictReason) { reasons = append(reasons, key+":"+reason.String()) },
+	})
+	c.now = func() time.Time { return now }
+
+	_ = c.Add("a", 1)
+	_ = c.AddWithTTL("b", 2, time.Second)
+	_ = c.AddWithTTL("c", 3, 0)
+
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Peek("b"); ok || c.Contains("b") {
+		t.Errorf("expired b should not be visible")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Get(b) ok = true, want expired")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v", v, ok)
+	}
+
+	now = now.Add(time.Hour)
+	if n := c.RemoveExpired(); n != 1 || c.Len() != 1 || !c.Contains("c") {
+		t.Errorf("RemoveExpired() = %d, Keys() = %v", n, c.Keys())
+	}
+	if want := []string{"b:expired", "a:expired"}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("reasons = %v, want %v", reasons, want)
+	}
+}
+
+func TestLRU_Cost(t *testing.T) {
+	var reasons []string
+	c := NewLRUWithConfig(LRUConfig[string, string]{
+		MaxCost: 10,
+		Cost:    func(key string, value string) int64 { return int64(len(value)) },
+		OnEvict: func(key string, value string, reason EvictReason) { reasons = append(reasons, key+":"+reason.String()) },
+	})
+	_ = c.Add("a", "1234")
+	_ = c.Add("b", "1234")
+	_ = c.Add("a", "12") // 覆盖
+	if c.Cost() != 6 {
+		t.Errorf("Cost() = %d, want 6", c.Cost())
+	}
+	_ = c.Add("c", "123456") // 超过 10, 淘汰最久没有访问的 b
+	if got, want := c.Keys(), []string{"c", "a"}; !reflect.DeepEqual(got, want) || c.Cost() != 8 {
+		t.Errorf("Keys() = %v, Cost() = %d", got, c.Cost())
+	}
+	_ = c.Add("d", "12345678901") // 单个超过 MaxCost, 全部淘汰
+	_ = c.Remove("missing")
+	if c.Len() != 0 || c.Cost() != 0 {
+		t.Errorf("Len() = %d, Cost() = %d, want 0", c.Len(), c.Cost())
+	}
+
+	want := []string{"a:replaced", "b:capacity", "a:capacity", "c:capacity", "d:capacity"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("reasons = %v, want %v", reasons, want)
+	}
+}
+
+func TestLRU_OnEvictRemoved(t *testing.T) {
+	var reasons []EvictReason
+	var calls int
+	c := NewLRUWithConfig(LRUConfig[int, int]{Max: 2, OnEvict: func(key int, value int, reason EvictReason) { reasons = append(reasons, reason) }})
+	c.Call = func(key int, value int) { calls++ }
+	_ = c.Add(1, 1)
+	_ = c.Add(1, 2)
+	_ = c.Add(2, 2)
+	_ = c.Remove(1)
+	c.Purge()
+	if want := []EvictReason{EvictReplaced, EvictRemoved, EvictRemoved}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("reasons = %v, want %v", reasons, want)
+	}
+	// Call 保持原来的语义, 覆盖时不调用
+	if calls != 2 {
+		t.Errorf("Call called %d times, want 2", calls)
+	}
+}
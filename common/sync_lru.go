@@ -0,0 +1,253 @@
+package common
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const defaultLRUShards = 16
+
+// SyncLRU 是并发安全的 LRU. key 按 hash 分到多个分片, 每个分片是一个独立加锁的 LRU,
+// 不同分片的读写互不阻塞.
+//
+// ⚠️注意: 淘汰在分片内进行, 只是近似的 LRU; Call 在持有分片锁时调用, 不能在 Call 中访问同一个缓存.
+type SyncLRU[K comparable, V any] struct {
+	shards []*lruShard[K, V]
+	mask   uint64
+
+	stop     chan struct{} // 关闭时停止 janitor
+	stopOnce sync.Once
+}
+
+// SyncLRUConfig 是 SyncLRU 的配置, Max 和 MaxCost 是总容量, 平均分到各个分片.
+type SyncLRUConfig[K comparable, V any] struct {
+	LRUConfig[K, V]
+	Shards          int           // 可选; 分片数, 向上取整到 2 的幂, 默认 16
+	JanitorInterval time.Duration // 可选; 大于 0 时后台每隔 JanitorInterval 删除过期的 key, 不再使用时需要调用 Close
+}
+
+// SyncLRUCache 是 key 和 value 都是 interface{} 的 SyncLRU.
+type SyncLRUCache = SyncLRU[interface{}, interface{}]
+
+type lruShard[K comparable, V any] struct {
+	mu  sync.Mutex
+	lru *LRU[K, V]
+}
+
+// NewSyncLRU 创建并发安全的 LRU, max 为总容量 (0 表示不限制), 平均分到各个分片;
+// shards 为分片数, 向上取整到 2 的幂, 小于等于 0 时默认 16, 总容量不足时减少分片数, 保证每个分片至少能放一个.
+func NewSyncLRU[K comparable, V any](max, shards int, call func(key K, value V)) *SyncLRU[K, V] {
+	c := NewSyncLRUWithConfig(SyncLRUConfig[K, V]{LRUConfig: LRUConfig[K, V]{Max: max}, Shards: shards})
+	for _, s := range c.shards {
+		s.lru.Call = call
+	}
+	return c
+}
+
+// NewSyncLRUWithConfig 按 config 创建并发安全的 LRU, 分片规则和 NewSyncLRU 相同.
+func NewSyncLRUWithConfig[K comparable, V any](config SyncLRUConfig[K, V]) *SyncLRU[K, V] {
+	shards := config.Shards
+	if shards <= 0 {
+		shards = defaultLRUShards
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	for config.Max > 0 && n > 1 && n > config.Max {
+		n >>= 1
+	}
+
+	shardConfig := config.LRUConfig
+	if config.Max > 0 {
+		shardConfig.Max = (config.Max + n - 1) / n
+	}
+	if config.MaxCost > 0 {
+		shardConfig.MaxCost = (config.MaxCost + int64(n) - 1) / int64(n)
+	}
+	c := &SyncLRU[K, V]{shards: make([]*lruShard[K, V], n), mask: uint64(n - 1), stop: make(chan struct{})}
+	for i := range c.shards {
+		c.shards[i] = &lruShard[K, V]{lru: NewLRUWithConfig(shardConfig)}
+	}
+	if config.JanitorInterval > 0 {
+		go c.janitor(config.JanitorInterval)
+	}
+	return c
+}
+
+func NewSyncLRUCache(max, shards int, call func(key interface{}, value interface{})) *SyncLRUCache {
+	return NewSyncLRU[interface{}, interface{}](max, shards, call)
+}
+
+func (c *SyncLRU[K, V]) shard(key K) *lruShard[K, V] {
+	return c.shards[hashKey(key)&c.mask]
+}
+
+func (c *SyncLRU[K, V]) Get(key K) (V, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Get(key)
+}
+
+// Peek 返回 key 对应的值, 不更新访问顺序.
+func (c *SyncLRU[K, V]) Peek(key K) (V, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Peek(key)
+}
+
+// Contains 判断 key 是否存在, 不更新访问顺序.
+func (c *SyncLRU[K, V]) Contains(key K) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Contains(key)
+}
+
+func (c *SyncLRU[K, V]) Add(key K, value V) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Add(key, value)
+}
+
+// GetOrAdd 和 LRU.GetOrAdd 相同, 查询和添加在同一把锁内完成.
+func (c *SyncLRU[K, V]) GetOrAdd(key K, value V) (actual V, loaded bool, err error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.GetOrAdd(key, value)
+}
+
+func (c *SyncLRU[K, V]) Remove(key K) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Remove(key)
+}
+
+// Len 返回所有分片的条目数之和, 并发写入时只是一个近似值.
+func (c *SyncLRU[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// AddWithTTL 添加或者覆盖 key, ttl 为 0 时不过期.
+func (c *SyncLRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.AddWithTTL(key, value, ttl)
+}
+
+// RemoveExpired 逐个分片删除过期的 key, 返回删除的个数.
+func (c *SyncLRU[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		removed += s.lru.RemoveExpired()
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// Cost 返回所有分片的开销之和.
+func (c *SyncLRU[K, V]) Cost() int64 {
+	var cost int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		cost += s.lru.Cost()
+		s.mu.Unlock()
+	}
+	return cost
+}
+
+// Close 停止 janitor, 可以重复调用, 关闭之后缓存仍然可以使用.
+func (c *SyncLRU[K, V]) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *SyncLRU[K, V]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.RemoveExpired()
+		}
+	}
+}
+
+// Purge 逐个分片删除所有 key.
+func (c *SyncLRU[K, V]) Purge() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.lru.Purge()
+		s.mu.Unlock()
+	}
+}
+
+// hashKey 计算 key 的 hash, 整数, 字符串和指针直接计算, 其他类型使用 fmt 格式化之后计算, 比较慢.
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	}
+	// 指针按地址计算, 不能按指向的内容, 否则内容修改之后会找不到
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return mix64(uint64(v.Pointer()))
+	default:
+		return hashString(fmt.Sprintf("%#v", key))
+	}
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 是 splitmix64 的最后一步, 让连续的整数均匀分到各个分片.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewSyncLRUCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		shards     int
+		wantShards int
+		wantMax    int
+	}{
+		{name: "default shards", max: 1024, shards: 0, wantShards: 16, wantMax: 64},
+		{name: "round up to power of 2", max: 100, shards: 5, wantShards: 8, wantMax: 13},
+		{name: "small capacity", max: 3, shards: 16, wantShards: 2, wantMax: 2},
+		{name: "no limit", max: 0, shards: 4, wantShards: 4, wantMax: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSyncLRUCache(tt.max, tt.shards, nil)
+			if len(c.shards) != tt.wantShards || c.shards[0].lru.max != tt.wantMax {
+				t.Errorf("NewSyncLRUCache() shards = %d, max per shard = %d, want %d, %d",
+					len(c.shards), c.shards[0].lru.max, tt.wantShards, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestSyncLRUCache(t *testing.T) {
+	var evicted int64
+	c := NewSyncLRUCache(64, 4, func(key interface{}, value interface{}) { atomic.AddInt64(&evicted, 1) })
+	for i := 0; i < 100; i++ {
+		if err := c.Add(i, i*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.Len() + int(evicted); got != 100 {
+		t.Errorf("Len() + evicted = %d, want 100", got)
+	}
+	if c.Len() > 64 {
+		t.Errorf("Len() = %d, want <= 64", c.Len())
+	}
+	if v, ok := c.Get(99); !ok || v != 990 {
+		t.Errorf("Get(99) = %v, %v", v, ok)
+	}
+	_ = c.Remove(99)
+	if _, ok := c.Get(99); ok {
+		t.Errorf("Get(99) after Remove ok = true")
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	type key struct {
+		a int
+		b string
+	}
+	p := &key{a: 1}
+	h := hashKey(p)
+	p.b = "changed"
+	if hashKey(p) != h {
+		t.Errorf("hashKey() of pointer changed with the pointed value")
+	}
+	if hashKey(key{1, "x"}) != hashKey(key{1, "x"}) || hashKey(key{1, "x"}) == hashKey(key{2, "x"}) {
+		t.Errorf("hashKey() of struct is not stable")
+	}
+
+	// 连续的 id 应该均匀分到各个分片
+	counts := make([]int, 16)
+	for i := 0; i < 16000; i++ {
+		counts[hashKey(i)&15]++
+	}
+	for shard, n := range counts {
+		if n < 800 || n > 1200 {
+			t.Errorf("shard %d has %d of 16000 keys", shard, n)
+		}
+	}
+}
+
+// TestSyncLRUCache_Race 需要使用 go test -race 运行.
+func TestSyncLRUCache_Race(t *testing.T) {
+	c := NewSyncLRUCache(128, 8, func(key interface{}, value interface{}) {})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := (g*31 + i) % 300
+				switch i % 4 {
+				case 0:
+					_ = c.Add(key, i)
+				case 1:
+					_ = c.Remove(key)
+				case 2:
+					_ = c.Len()
+				default:
+					c.Get(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if c.Len() > 128+8 {
+		t.Errorf("Len() = %d, exceeds capacity", c.Len())
+	}
+}
+
+func benchmarkKeys(n int) []interface{} {
+	keys := make([]interface{}, n)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// BenchmarkLRUCache 是单线程的 LRUCache, 作为基准.
+func BenchmarkLRUCache(b *testing.B) {
+	keys := benchmarkKeys(4096)
+	c := NewLRUCache(1024, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		if _, ok := c.Get(key); !ok {
+			_ = c.Add(key, i)
+		}
+	}
+}
+
+// BenchmarkLRUCache_Mutex 是多个 goroutine 共用一把锁保护的 LRUCache.
+func BenchmarkLRUCache_Mutex(b *testing.B) {
+	keys := benchmarkKeys(4096)
+	c := NewLRUCache(1024, nil)
+	var mu sync.Mutex
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			mu.Lock()
+			if _, ok := c.Get(key); !ok {
+				_ = c.Add(key, i)
+			}
+			mu.Unlock()
+			i++
+		}
+	})
+}
+
+func BenchmarkSyncLRUCache(b *testing.B) {
+	keys := benchmarkKeys(4096)
+	c := NewSyncLRUCache(1024, 0, nil)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if _, ok := c.Get(key); !ok {
+				_ = c.Add(key, i)
+			}
+			i++
+		}
+	})
+}
+
+func TestSyncLRU_Janitor(t *testing.T) {
+	expired := make(chan int, 10)
+	c := NewSyncLRUWithConfig(SyncLRUConfig[int, int]{
+		LRUConfig: LRUConfig[int, int]{
+			MaxCost: 100,
+			TTL:     10 * time.Millisecond,
+			OnEvict: func(key int, value int, reason EvictReason) {
+				if reason == EvictExpired {
+					expired <- key
+				}
+			},
+		},
+		Shards:          4,
+		JanitorInterval: 5 * time.Millisecond,
+	})
+	defer c.Close()
+	if got := c.shards[0].lru.maxCost; got != 25 {
+		t.Errorf("max cost per shard = %d, want 25", got)
+	}
+
+	_ = c.Add(1, 1)
+	_ = c.AddWithTTL(2, 2, time.Hour)
+	select {
+	case key := <-expired:
+		if key != 1 {
+			t.Errorf("expired key = %d, want 1", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not remove the expired key")
+	}
+	if c.Len() != 1 || !c.Contains(2) || c.Cost() != 1 {
+		t.Errorf("Len() = %d, Cost() = %d", c.Len(), c.Cost())
+	}
+	c.Close()
+}
@@ -0,0 +1,136 @@
+package common
+
+const (
+	tinyLFUWindowRatio = 0.01 // window LRU 占总容量的比例
+	tinyLFUDepth       = 4    // count-min sketch 的行数
+	tinyLFUMaxCount    = 15   // 每个计数器的上限
+	tinyLFUResetFactor = 10   // 记录 size * 10 次访问之后所有计数减半
+)
+
+// TinyLFU 是 W-TinyLFU 缓存: 新 key 先进入很小的 window LRU, 从 window 淘汰的 key 只有在访问频率
+// 高于 main LRU 中将被淘汰的 key 时才会进入 main, 否则直接丢弃. 访问频率由定期衰减的 count-min sketch 估算,
+// 只在 Get 时记录, 所以一次性扫描的冷 key 无法挤掉热点 key.
+type TinyLFU[K comparable, V any] struct {
+	windowSize int
+	mainSize   int
+	window     *LRU[K, V]
+	main       *LRU[K, V]
+	sketch     *countMinSketch
+}
+
+// NewTinyLFU 创建容量为 size 的 W-TinyLFU 缓存, size 必须大于 0.
+func NewTinyLFU[K comparable, V any](size int) *TinyLFU[K, V] {
+	if size <= 0 {
+		size = 1
+	}
+	windowSize := max(int(float64(size)*tinyLFUWindowRatio), 1)
+	return &TinyLFU[K, V]{
+		windowSize: windowSize,
+		mainSize:   size - windowSize,
+		window:     NewLRU[K, V](0, nil),
+		main:       NewLRU[K, V](0, nil),
+		sketch:     newCountMinSketch(size),
+	}
+}
+
+func (c *TinyLFU[K, V]) Get(key K) (V, bool) {
+	c.sketch.add(hashKey(key))
+	if v, ok := c.window.Get(key); ok {
+		return v, true
+	}
+	return c.main.Get(key)
+}
+
+func (c *TinyLFU[K, V]) Add(key K, value V) error {
+	if c.main.Contains(key) {
+		return c.main.Add(key, value)
+	}
+	if err := c.window.Add(key, value); err != nil {
+		return err
+	}
+	for c.window.Len() > c.windowSize {
+		candidate, v, _ := c.window.RemoveOldest()
+		c.admit(candidate, v)
+	}
+	return nil
+}
+
+// admit 决定从 window 淘汰的 candidate 是否进入 main, main 已满时和 main 中最久没有访问的 key 比较访问频率.
+func (c *TinyLFU[K, V]) admit(candidate K, value V) {
+	if c.mainSize <= 0 {
+		return
+	}
+	if c.main.Len() < c.mainSize {
+		_ = c.main.Add(candidate, value)
+		return
+	}
+	victim := c.main.l.Back().Value.(*node[K, V]).key
+	if c.sketch.estimate(hashKey(candidate)) > c.sketch.estimate(hashKey(victim)) {
+		c.main.RemoveOldest()
+		_ = c.main.Add(candidate, value)
+	}
+}
+
+func (c *TinyLFU[K, V]) Remove(key K) error {
+	_ = c.window.Remove(key)
+	return c.main.Remove(key)
+}
+
+func (c *TinyLFU[K, V]) Len() int {
+	return c.window.Len() + c.main.Len()
+}
+
+// countMinSketch 用 4 行计数器估算 key 的访问次数, 估算值不会小于真实值.
+// 记录的次数达到 size * 10 时所有计数减半, 让以前的热点逐渐过期.
+type countMinSketch struct {
+	mask      uint64
+	rows      [tinyLFUDepth][]uint8
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(size int) *countMinSketch {
+	width := 1
+	for width < size*2 {
+		width <<= 1
+	}
+	s := &countMinSketch{mask: uint64(width - 1), resetAt: size * tinyLFUResetFactor}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index 返回 hash 在第 i 行的位置, 每行使用不同的种子混合.
+func (s *countMinSketch) index(hash uint64, i int) uint64 {
+	return mix64(hash+uint64(i+1)*0x9e3779b97f4a7c15) & s.mask
+}
+
+func (s *countMinSketch) add(hash uint64) {
+	for i := range s.rows {
+		if idx := s.index(hash, i); s.rows[i][idx] < tinyLFUMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(hash uint64) uint8 {
+	count := uint8(tinyLFUMaxCount)
+	for i := range s.rows {
+		count = min(count, s.rows[i][s.index(hash, i)])
+	}
+	return count
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,91 @@
+package common
+
+const (
+	twoQueueRecentRatio = 0.25 // recent 队列占总容量的比例
+	twoQueueGhostRatio  = 0.5  // ghost 队列的容量占总容量的比例
+)
+
+// TwoQueue 是简化的 2Q 缓存: 新 key 先进入 recent 队列, 再次访问时才进入 frequent 队列,
+// 从 recent 淘汰的 key 记录在 ghost 队列中, 短时间内再次添加时直接进入 frequent.
+// 一次性扫描的 key 只会冲掉 recent 队列, 不会影响 frequent 中的热点 key.
+type TwoQueue[K comparable, V any] struct {
+	size       int
+	recentSize int
+	recent     *LRU[K, V]
+	frequent   *LRU[K, V]
+	ghost      *LRU[K, struct{}] // 只记录 key
+}
+
+// NewTwoQueue 创建容量为 size 的 2Q 缓存, size 必须大于 0.
+func NewTwoQueue[K comparable, V any](size int) *TwoQueue[K, V] {
+	if size <= 0 {
+		size = 1
+	}
+	ghostSize := int(float64(size) * twoQueueGhostRatio)
+	if ghostSize < 1 {
+		ghostSize = 1
+	}
+	return &TwoQueue[K, V]{
+		size:       size,
+		recentSize: int(float64(size) * twoQueueRecentRatio),
+		recent:     NewLRU[K, V](0, nil),
+		frequent:   NewLRU[K, V](0, nil),
+		ghost:      NewLRU[K, struct{}](ghostSize, nil),
+	}
+}
+
+func (c *TwoQueue[K, V]) Get(key K) (V, bool) {
+	if v, ok := c.frequent.Get(key); ok {
+		return v, true
+	}
+	if v, ok := c.recent.Peek(key); ok {
+		_ = c.recent.Remove(key)
+		_ = c.frequent.Add(key, v)
+		return v, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *TwoQueue[K, V]) Add(key K, value V) error {
+	if c.frequent.Contains(key) {
+		return c.frequent.Add(key, value)
+	}
+	if c.recent.Contains(key) {
+		_ = c.recent.Remove(key)
+		return c.frequent.Add(key, value)
+	}
+	if c.ghost.Contains(key) {
+		c.ensureSpace(true)
+		_ = c.ghost.Remove(key)
+		return c.frequent.Add(key, value)
+	}
+	c.ensureSpace(false)
+	return c.recent.Add(key, value)
+}
+
+// ensureSpace 在添加之前腾出一个位置, recent 超过 recentSize 时优先淘汰 recent.
+func (c *TwoQueue[K, V]) ensureSpace(ghost bool) {
+	recentLen := c.recent.Len()
+	if recentLen+c.frequent.Len() < c.size {
+		return
+	}
+	if recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !ghost)) {
+		key, _, _ := c.recent.RemoveOldest()
+		_ = c.ghost.Add(key, struct{}{})
+		return
+	}
+	if _, _, ok := c.frequent.RemoveOldest(); !ok {
+		c.recent.RemoveOldest()
+	}
+}
+
+func (c *TwoQueue[K, V]) Remove(key K) error {
+	_ = c.frequent.Remove(key)
+	_ = c.recent.Remove(key)
+	return c.ghost.Remove(key)
+}
+
+func (c *TwoQueue[K, V]) Len() int {
+	return c.recent.Len() + c.frequent.Len()
+}
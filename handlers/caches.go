@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"demo-to-start/common"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const maxHotKeys = 100
+
+// CacheHandlerConfig 是缓存管理接口的配置.
+type CacheHandlerConfig struct {
+	Registry *common.CacheRegistry // 必须; 需要查看的缓存
+}
+
+// CacheHandler 是缓存管理接口, 只应该在内部端口或者经过鉴权之后访问.
+type CacheHandler struct {
+	registry *common.CacheRegistry
+}
+
+// NewCacheHandler 创建缓存管理接口.
+func NewCacheHandler(config CacheHandlerConfig) (*CacheHandler, error) {
+	if config.Registry == nil {
+		return nil, errors.New("nil cache registry")
+	}
+	return &CacheHandler{registry: config.Registry}, nil
+}
+
+// Caches 处理 GET /debug/caches?hot=10: 返回所有缓存的统计数据, hot 大于 0 时同时返回每个缓存访问最多的 key.
+func (h *CacheHandler) Caches(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.caches).ServeHTTP(w, r)
+}
+
+func (h *CacheHandler) caches(w http.ResponseWriter, r *http.Request) error {
+	var hot int
+	if s := r.URL.Query().Get("hot"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxHotKeys {
+			return InvalidParam(fmt.Sprintf("hot must be between 0 and %d", maxHotKeys))
+		}
+		hot = n
+	}
+	WriteSuccess(w, r, http.StatusOK, h.registry.Report(hot))
+	return nil
+}
+
+// Metrics 处理 GET /debug/caches/metrics: 以 Prometheus 文本格式返回所有缓存的统计数据.
+func (h *CacheHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.metrics).ServeHTTP(w, r)
+}
+
+// cacheMetrics 是导出的指标, value 从统计数据中取值.
+var cacheMetrics = []struct {
+	name, typ, help string
+	value           func(s common.CacheStats) float64
+}{
+	{"cache_hits_total", "counter", "Cache lookups that found a live entry.", func(s common.CacheStats) float64 { return float64(s.Hits) }},
+	{"cache_misses_total", "counter", "Cache lookups that found no entry or an expired one.", func(s common.CacheStats) float64 { return float64(s.Misses) }},
+	{"cache_insertions_total", "counter", "Entries added to the cache, excluding overwrites.", func(s common.CacheStats) float64 { return float64(s.Insertions) }},
+	{"cache_loads_total", "counter", "Read-through loads, including failed ones.", func(s common.CacheStats) float64 { return float64(s.Loads) }},
+	{"cache_load_errors_total", "counter", "Read-through loads that returned an error.", func(s common.CacheStats) float64 { return float64(s.LoadErrors) }},
+	{"cache_load_duration_seconds_total", "counter", "Total time spent in read-through loads.", func(s common.CacheStats) float64 { return s.LoadTime.Seconds() }},
+	{"cache_entries", "gauge", "Current number of entries.", func(s common.CacheStats) float64 { return float64(s.Size) }},
+	{"cache_cost", "gauge", "Current total cost of all entries.", func(s common.CacheStats) float64 { return float64(s.Cost) }},
+	{"cache_max_entries", "gauge", "Maximum number of entries, 0 means unlimited.", func(s common.CacheStats) float64 { return float64(s.Max) }},
+}
+
+// evictionReasons 是 cache_evictions_total 的 reason 标签.
+var evictionReasons = []struct {
+	reason common.EvictReason
+	value  func(e common.EvictionStats) uint64
+}{
+	{common.EvictCapacity, func(e common.EvictionStats) uint64 { return e.Capacity }},
+	{common.EvictExpired, func(e common.EvictionStats) uint64 { return e.Expired }},
+	{common.EvictRemoved, func(e common.EvictionStats) uint64 { return e.Removed }},
+	{common.EvictReplaced, func(e common.EvictionStats) uint64 { return e.Replaced }},
+}
+
+func (h *CacheHandler) metrics(w http.ResponseWriter, r *http.Request) error {
+	reports := h.registry.Report(0)
+
+	var buf bytes.Buffer
+	for _, m := range cacheMetrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, report := range reports {
+			fmt.Fprintf(&buf, "%s{cache=%q} %s\n", m.name, report.Name, strconv.FormatFloat(m.value(report.Stats), 'g', -1, 64))
+		}
+	}
+	buf.WriteString("# HELP cache_evictions_total Entries removed from the cache, by reason.\n# TYPE cache_evictions_total counter\n")
+	for _, report := range reports {
+		for _, e := range evictionReasons {
+			fmt.Fprintf(&buf, "cache_evictions_total{cache=%q,reason=%q} %d\n", report.Name, e.reason, e.value(report.Stats.Evictions))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(buf.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"demo-to-start/common"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestCacheHandler(t *testing.T) *CacheHandler {
+	t.Helper()
+	registry := common.NewCacheRegistry()
+	lru := common.NewLRU[string, int](10, nil)
+	_ = lru.Add("a", 1)
+	lru.Get("a")
+	lru.Get("b")
+	if err := registry.Register("users", lru); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewCacheHandler(CacheHandlerConfig{Registry: registry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestCacheHandler_Caches(t *testing.T) {
+	h := newTestCacheHandler(t)
+	router := NewRouter()
+	router.Get("/debug/caches", h.Caches)
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantHot    int
+	}{
+		{name: "stats", method: http.MethodGet, url: "/debug/caches", wantStatus: http.StatusOK},
+		{name: "hot keys", method: http.MethodGet, url: "/debug/caches?hot=5", wantStatus: http.StatusOK, wantHot: 1},
+		{name: "invalid hot", method: http.MethodGet, url: "/debug/caches?hot=1000", wantStatus: http.StatusBadRequest},
+		{name: "method", method: http.MethodPost, url: "/debug/caches", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Code int
+				Data []common.CacheReport
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+			}
+			if len(resp.Data) != 1 || resp.Data[0].Name != "users" || resp.Data[0].Stats.Hits != 1 || len(resp.Data[0].HotKeys) != tt.wantHot {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCacheHandler_Metrics(t *testing.T) {
+	h := newTestCacheHandler(t)
+	w := httptest.NewRecorder()
+	h.Metrics(w, httptest.NewRequest(http.MethodGet, "/debug/caches/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status = %v, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE cache_hits_total counter\n",
+		`cache_hits_total{cache="users"} 1` + "\n",
+		`cache_misses_total{cache="users"} 1` + "\n",
+		`cache_entries{cache="users"} 1` + "\n",
+		`cache_evictions_total{cache="users",reason="capacity"} 0` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
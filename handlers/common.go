@@ -1,7 +1,90 @@
 package handlers
 
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Response 是所有接口统一的响应格式, 成功和失败都使用该格式.
 type Response struct {
 	Code int
 	Msg  string
 	Data interface{}
 }
+
+// 响应码, Response.Code 除 CodeSuccess 以外都表示失败.
+const (
+	CodeSuccess          = 1     // 成功, HTTP 200/201
+	CodeInvalidParam     = 40000 // 请求参数错误, HTTP 400
+	CodeNotFound         = 40400 // 资源不存在, HTTP 404
+	CodeMethodNotAllowed = 40500 // 不支持的 HTTP 方法, HTTP 405
+	CodeInternal         = 50000 // 服务内部错误, HTTP 500, 不返回具体原因
+	CodeUnavailable      = 50300 // 服务未就绪, HTTP 503
+)
+
+// Error 是返回给客户端的错误, 其他类型的错误都按 CodeInternal 返回, 防止泄漏内部错误.
+type Error struct {
+	Status int    // HTTP 状态码
+	Code   int    // Response.Code
+	Msg    string // Response.Msg
+}
+
+func (e *Error) Error() string { return e.Msg }
+
+// InvalidParam 返回请求参数错误.
+func InvalidParam(msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidParam, Msg: msg}
+}
+
+// NotFound 返回资源不存在错误.
+func NotFound(msg string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Msg: msg}
+}
+
+// MethodNotAllowed 设置 Allow 响应头并返回 HTTP 方法不支持错误.
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) *Error {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	return &Error{Status: http.StatusMethodNotAllowed, Code: CodeMethodNotAllowed, Msg: "method not allowed"}
+}
+
+var errInternal = &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Msg: "internal server error"}
+
+// HandlerFunc 是返回 error 的 http 处理函数, 返回的 error 由 WriteError 统一写入响应.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := fn(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
+
+// WriteSuccess 写入成功的响应.
+func WriteSuccess(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	WriteJSON(w, r, status, Response{Code: CodeSuccess, Msg: "success", Data: data})
+}
+
+// WriteError 写入失败的响应, err 不是 *Error 时按 CodeInternal 返回.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = errInternal
+	}
+	WriteJSON(w, r, e.Status, Response{Code: e.Code, Msg: e.Msg})
+}
+
+// WriteJSON 以 json 格式写入响应, HEAD 请求只写入响应头.
+func WriteJSON(w http.ResponseWriter, r *http.Request, status int, resp Response) {
+	bd, err := json.Marshal(resp)
+	if err != nil {
+		status = errInternal.Status
+		bd, _ = json.Marshal(Response{Code: errInternal.Code, Msg: errInternal.Msg})
+	}
+	// 设置响应头
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(bd)
+	}
+}
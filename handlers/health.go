@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"demo-to-start/health"
+	"errors"
+	"net/http"
+)
+
+// HealthHandlerConfig 是健康检查接口的配置.
+type HealthHandlerConfig struct {
+	Readiness *health.Readiness // 必须; 就绪检查
+}
+
+// HealthHandler 是存活和就绪探测接口.
+type HealthHandler struct {
+	readiness *health.Readiness
+}
+
+// NewHealthHandler 创建健康检查接口.
+func NewHealthHandler(config HealthHandlerConfig) (*HealthHandler, error) {
+	if config.Readiness == nil {
+		return nil, errors.New("nil readiness")
+	}
+	return &HealthHandler{readiness: config.Readiness}, nil
+}
+
+// Healthz 处理 GET /healthz: 进程存活并且可以处理请求, 不检查依赖.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.healthz).ServeHTTP(w, r)
+}
+
+func (h *HealthHandler) healthz(w http.ResponseWriter, r *http.Request) error {
+	WriteSuccess(w, r, http.StatusOK, map[string]health.Status{"status": health.StatusUp})
+	return nil
+}
+
+// Readyz 处理 GET /readyz: 返回每个依赖的检查结果, 有依赖不可用或者正在停止时返回 503.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.readyz).ServeHTTP(w, r)
+}
+
+func (h *HealthHandler) readyz(w http.ResponseWriter, r *http.Request) error {
+	report := h.readiness.Check(r.Context())
+	if report.Status != health.StatusUp {
+		WriteJSON(w, r, http.StatusServiceUnavailable, Response{Code: CodeUnavailable, Msg: "not ready", Data: report})
+		return nil
+	}
+	WriteSuccess(w, r, http.StatusOK, report)
+	return nil
+}
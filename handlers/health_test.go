@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/health"
+	"demo-to-start/logger"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	readiness := health.NewReadiness(health.Config{Interval: time.Nanosecond, Logger: logger.Nop()})
+	var mysqlErr common.Error
+	_ = readiness.Register("mysql", health.CheckerFunc(func(context.Context) error { return mysqlErr.Load() }))
+	h, err := NewHealthHandler(HealthHandlerConfig{Readiness: readiness})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter()
+	router.Get("/healthz", h.Healthz)
+	router.Get("/readyz", h.Readyz)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		mysqlErr   error
+		shutdown   bool
+		wantStatus int
+		wantCode   int
+		wantCheck  health.Status
+	}{
+		{name: "alive", method: http.MethodGet, path: "/healthz", wantStatus: http.StatusOK, wantCode: CodeSuccess},
+		{name: "alive method", method: http.MethodPost, path: "/healthz", wantStatus: http.StatusMethodNotAllowed, wantCode: CodeMethodNotAllowed},
+		{name: "ready", method: http.MethodGet, path: "/readyz", wantStatus: http.StatusOK, wantCode: CodeSuccess, wantCheck: health.StatusUp},
+		{name: "not ready", method: http.MethodGet, path: "/readyz", mysqlErr: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable, wantCode: CodeUnavailable, wantCheck: health.StatusDown},
+		{name: "shutting down", method: http.MethodGet, path: "/readyz", shutdown: true, wantStatus: http.StatusServiceUnavailable, wantCode: CodeUnavailable, wantCheck: health.StatusDown},
+		// 停止期间存活探测仍然成功, 不会被重启
+		{name: "alive while shutting down", method: http.MethodGet, path: "/healthz", wantStatus: http.StatusOK, wantCode: CodeSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysqlErr.Store(tt.mysqlErr)
+			if tt.shutdown {
+				_ = readiness.Shutdown(context.Background())
+			}
+			time.Sleep(time.Millisecond) // 超过 Interval, 重新检查
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			var resp struct {
+				Code int
+				Data health.Report
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.wantStatus || resp.Code != tt.wantCode {
+				t.Fatalf("status = %v, code = %v, want %v %v, body %s", w.Code, resp.Code, tt.wantStatus, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCheck != "" && (len(resp.Data.Checks) != 1 || resp.Data.Checks[0].Status != tt.wantCheck) {
+				t.Errorf("checks = %+v, want %v", resp.Data.Checks, tt.wantCheck)
+			}
+		})
+	}
+}
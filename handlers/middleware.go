@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"demo-to-start/logger"
+	"demo-to-start/trace"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader 是请求 id 的请求头和响应头.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen 是接受的请求 id 的最大长度, 超过或者包含不可见字符时重新生成, 防止日志注入.
+const maxRequestIDLen = 128
+
+// Middleware 包装 http.Handler, 用于请求 id, 访问日志等接口共用的逻辑.
+type Middleware func(http.Handler) http.Handler
+
+// Chain 把 middlewares 组合为一个 Middleware, 第一个在最外层, 最先处理请求.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext 返回 RequestID 保存在 ctx 中的请求 id, 没有则返回空字符串.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID 使用请求头 X-Request-ID 作为请求 id, 没有或者不合法时生成一个新的, 写入响应头并保存到 context,
+// 之后使用该 context 的日志都会输出 request_id 字段.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = logger.WithFields(ctx, "request_id", id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Trace 从请求头 traceparent 中解析上游的 trace context 保存到 r.Context(), handler 中 trace.Start 创建的 span 延续上游的 trace.
+// 请求头不存在或者不合法时开始新的 trace.
+func Trace() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := trace.Extract(r.Context(), trace.HeaderCarrier(r.Header))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLog 在请求结束之后打印访问日志: method, path, status, bytes, latency, 5xx 按 error 级别打印.
+// l 为 nil 时使用 logger.Default().
+func AccessLog(l logger.Logger) Middleware {
+	if l == nil {
+		l = logger.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			begin := time.Now()
+			rw := wrapResponseWriter(w)
+			defer func() {
+				status := rw.Status()
+				fields := []interface{}{
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", status,
+					"bytes", rw.bytes,
+					"latency", time.Since(begin).String(),
+					"remote_addr", r.RemoteAddr,
+				}
+				if status >= http.StatusInternalServerError {
+					l.Error(r.Context(), "http-access", fields...)
+				} else {
+					l.Info(r.Context(), "http-access", fields...)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Recover 恢复处理请求时的 panic, 打印日志和调用栈, 还没有写入响应时返回 CodeInternal.
+// http.ErrAbortHandler 是主动中断请求, 继续 panic. l 为 nil 时使用 logger.Default().
+func Recover(l logger.Logger) Middleware {
+	if l == nil {
+		l = logger.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := wrapResponseWriter(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				l.Error(r.Context(), "http-handler-panic", "method", r.Method, "path", r.URL.Path,
+					"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				if !rw.wroteHeader {
+					WriteError(rw, r, errInternal)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Timeout 限制处理请求的时间: r.Context() 在 d 之后结束, 超时时返回 503 和 CodeUnavailable,
+// 之后 handler 写入的响应被丢弃. 用于单个路由, 例如 Timeout(3*time.Second, l)(handler).
+// 超时之前的 panic 交给外层的 Recover 处理, 超时之后的 panic 已经没有请求可以响应, 和 Recover 一样使用 l 打印日志和调用栈.
+// l 为 nil 时使用 logger.Default().
+func Timeout(d time.Duration, l logger.Logger) Middleware {
+	if l == nil {
+		l = logger.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicc := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					p := recover()
+					if p == nil {
+						return
+					}
+					tw.mu.Lock()
+					defer tw.mu.Unlock()
+					if tw.timedOut {
+						if p != http.ErrAbortHandler {
+							l.Error(r.Context(), "http-handler-panic", "method", r.Method, "path", r.URL.Path,
+								"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+						}
+						return
+					}
+					// 带上 handler 所在 goroutine 的调用栈, 外层 Recover 只能看到 Timeout 的调用栈
+					if p != http.ErrAbortHandler {
+						p = fmt.Sprintf("%v\n\n%s", p, debug.Stack())
+					}
+					panicc <- p
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+			select {
+			case p := <-panicc:
+				// 在当前 goroutine 中继续 panic, 由外层的 Recover 处理
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.wroteHeader {
+					w.WriteHeader(tw.status)
+				}
+				_, _ = w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				// 和超时同时发生的 panic 已经发送到 panicc, 仍然交给外层的 Recover
+				select {
+				case p := <-panicc:
+					tw.mu.Unlock()
+					panic(p)
+				default:
+				}
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				WriteJSON(w, r, http.StatusServiceUnavailable, Response{Code: CodeUnavailable, Msg: "request timeout"})
+			}
+		})
+	}
+}
+
+// timeoutWriter 缓存 handler 写入的响应, handler 在超时之前完成时才写入真正的 ResponseWriter.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.wroteHeader, tw.status = true, http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader, tw.status = true, status
+}
+
+// responseWriter 记录响应的状态码和字节数, 用于访问日志和 Recover.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// wrapResponseWriter 包装 w, 已经包装过时直接返回, 多个 middleware 共用同一个记录.
+func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Status 返回写入的状态码, 没有写入时为 200.
+func (rw *responseWriter) Status() int {
+	if !rw.wroteHeader {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Unwrap 用于 http.ResponseController 访问原始的 ResponseWriter.
+func (rw *responseWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
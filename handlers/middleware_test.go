@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"demo-to-start/logger"
+	"demo-to-start/trace"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(mark("a"), mark("b"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { order = append(order, "handler") }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "propagate", header: "req-123", keep: true},
+		{name: "generate", header: ""},
+		{name: "invalid", header: "bad id\n"},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLen+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, field string
+			h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = RequestIDFromContext(r.Context())
+				if fields := logger.FieldsFromContext(r.Context()); len(fields) == 2 && fields[0] == "request_id" {
+					field, _ = fields[1].(string)
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if got == "" || got != field || w.Header().Get(RequestIDHeader) != got {
+				t.Fatalf("id = %q, log field = %q, response header = %q", got, field, w.Header().Get(RequestIDHeader))
+			}
+			if (got == tt.header) != tt.keep {
+				t.Errorf("id = %q, header %q", got, tt.header)
+			}
+		})
+	}
+}
+
+func TestAccessLogAndRecover(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.NewJSONLogger(&buf, logger.LevelInfo)
+	h := Chain(RequestID(), AccessLog(l), Recover(l))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		WriteSuccess(w, r, http.StatusCreated, "ok")
+	}))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   int
+	}{
+		{path: "/users", wantStatus: http.StatusCreated, wantCode: CodeSuccess},
+		{path: "/panic", wantStatus: http.StatusInternalServerError, wantCode: CodeInternal},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != tt.wantStatus || resp.Code != tt.wantCode {
+			t.Fatalf("%s: status = %d, body = %s", tt.path, w.Code, w.Body.String())
+		}
+
+		// 最后一行是访问日志, panic 时前面还有一行带调用栈的错误日志
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var access map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &access); err != nil {
+			t.Fatalf("invalid log %q: %v", buf.String(), err)
+		}
+		if access["msg"] != "http-access" || access["method"] != "POST" || access["path"] != tt.path ||
+			access["status"] != float64(tt.wantStatus) || access["bytes"] != float64(w.Body.Len()) ||
+			access["latency"] == nil || access["request_id"] != w.Header().Get(RequestIDHeader) {
+			t.Errorf("access log = %v", access)
+		}
+		if tt.wantStatus == http.StatusInternalServerError && (len(lines) != 2 || !strings.Contains(lines[0], "http-handler-panic") || !strings.Contains(lines[0], "boom")) {
+			t.Errorf("panic log = %q", lines[0])
+		}
+	}
+}
+
+func TestRecover_AfterWrite(t *testing.T) {
+	h := Recover(logger.Nop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteSuccess(w, r, http.StatusOK, "partial")
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	// 已经写入的响应不能再修改, 只打印日志
+	if w.Code != http.StatusOK || strings.Count(w.Body.String(), "Code") != 1 {
+		t.Errorf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var got trace.SpanContext
+	h := Trace()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !got.Remote || !got.Sampled {
+		t.Errorf("SpanContext = %+v", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := Chain(Recover(logger.Nop()), Timeout(20*time.Millisecond, logger.Nop()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			WriteSuccess(w, r, http.StatusOK, "late")
+		case "/panic":
+			panic("boom")
+		default:
+			w.Header().Set("X-Handler", "fast")
+			WriteSuccess(w, r, http.StatusCreated, "fast")
+		}
+	}))
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   int
+	}{
+		{path: "/fast", wantStatus: http.StatusCreated, wantCode: CodeSuccess},
+		{path: "/slow", wantStatus: http.StatusServiceUnavailable, wantCode: CodeUnavailable},
+		{path: "/panic", wantStatus: http.StatusInternalServerError, wantCode: CodeInternal},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != tt.wantStatus || resp.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, body = %s", tt.path, w.Code, w.Body.String())
+		}
+		if tt.path == "/fast" && w.Header().Get("X-Handler") != "fast" {
+			t.Errorf("%s: headers = %v", tt.path, w.Header())
+		}
+	}
+}
+
+func TestTimeout_PanicAfterDeadline(t *testing.T) {
+	var buf syncBuffer
+	l := logger.NewJSONLogger(&buf, logger.LevelInfo)
+	logged := make(chan struct{})
+	h := Chain(Recover(l), Timeout(10*time.Millisecond, l))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(logged)
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		panic("late boom")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	<-logged
+	// 超时之后的 panic 没有请求可以响应, 由 handler 所在的 goroutine 打印日志
+	for i := 0; i < 100 && !strings.Contains(buf.String(), "late boom"); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if got := buf.String(); !strings.Contains(got, "http-handler-panic") || !strings.Contains(got, "late boom") || !strings.Contains(got, "stack") {
+		t.Errorf("log = %q", got)
+	}
+}
+
+func TestTimeout_Deadline(t *testing.T) {
+	var deadline time.Time
+	h := Timeout(time.Minute, logger.Nop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.Background()))
+	if time.Until(deadline) <= 0 || time.Until(deadline) > time.Minute {
+		t.Errorf("deadline = %v", deadline)
+	}
+}
+
+// syncBuffer 是并发安全的 bytes.Buffer, 用于其他 goroutine 打印的日志.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Router 按 HTTP 方法和路径分发请求, 替代 http.ServeMux.
+//
+// 路径按 "/" 分段精确匹配, 末尾的 "/" 被忽略; "{name}" 匹配任意一段, 通过 PathParam 读取, 同一位置静态段优先.
+// 路径存在但方法不匹配时返回 405 并设置 Allow 响应头; 没有注册 HEAD 时使用 GET 的 handler,
+// 没有注册 OPTIONS 时返回 204 和 Allow 响应头. 路由注册只能在处理请求之前完成, 冲突的路由会 panic.
+type Router struct {
+	RouteGroup
+	root        *routeNode
+	middlewares []Middleware
+	handler     http.Handler // 经过 middlewares 的 dispatch
+}
+
+// RouteGroup 是共享路径前缀和 middleware 的一组路由, 通过 Router.Group 创建.
+type RouteGroup struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
+}
+
+// routeNode 是路由树的一个节点, 对应路径中的一段.
+type routeNode struct {
+	static   map[string]*routeNode
+	param    *routeNode
+	name     string                  // param 节点的参数名
+	handlers map[string]http.Handler // 方法到 handler, 为空时该路径没有注册路由
+}
+
+// NewRouter 创建 Router.
+func NewRouter() *Router {
+	r := &Router{root: &routeNode{}}
+	r.RouteGroup = RouteGroup{router: r}
+	r.handler = http.HandlerFunc(r.dispatch)
+	return r
+}
+
+// Use 添加作用于所有请求的 middleware, 包括 404 和 405, 第一个在最外层.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.handler = Chain(r.middlewares...)(http.HandlerFunc(r.dispatch))
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) {
+	node, params := r.root.match(splitPath(req.URL.EscapedPath()))
+	if node == nil || len(node.handlers) == 0 {
+		WriteError(w, req, NotFound("route not found"))
+		return
+	}
+	h, ok := node.handlers[req.Method]
+	switch {
+	case ok:
+	case req.Method == http.MethodHead && node.handlers[http.MethodGet] != nil:
+		h = node.handlers[http.MethodGet]
+	case req.Method == http.MethodOptions:
+		w.Header().Set("Allow", strings.Join(node.allowed(), ", "))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		WriteError(w, req, MethodNotAllowed(w, node.allowed()...))
+		return
+	}
+	if len(params) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), pathParamsKey{}, params))
+	}
+	h.ServeHTTP(w, req)
+}
+
+// Group 创建路径前缀为 prefix 的路由组, middlewares 作用于组内的路由, 在外层组的 middleware 之内.
+func (g *RouteGroup) Group(prefix string, middlewares ...Middleware) *RouteGroup {
+	mws := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	mws = append(mws, g.middlewares...)
+	mws = append(mws, middlewares...)
+	return &RouteGroup{router: g.router, prefix: g.prefix + strings.TrimSuffix(prefix, "/"), middlewares: mws}
+}
+
+// Handle 注册 method 和 pattern 的路由, pattern 相对于组的前缀.
+func (g *RouteGroup) Handle(method, pattern string, h http.Handler) {
+	if method == "" || h == nil {
+		panic(fmt.Sprintf("router: invalid route %s %s", method, pattern))
+	}
+	full := g.prefix + pattern
+	if !strings.HasPrefix(full, "/") {
+		panic(fmt.Sprintf("router: pattern %q must start with /", full))
+	}
+	node := g.router.root.insert(splitPath(full), full)
+	if _, ok := node.handlers[method]; ok {
+		panic(fmt.Sprintf("router: duplicate route %s %s", method, full))
+	}
+	if node.handlers == nil {
+		node.handlers = make(map[string]http.Handler)
+	}
+	node.handlers[method] = Chain(g.middlewares...)(h)
+}
+
+// HandleFunc 注册 method 和 pattern 的路由.
+func (g *RouteGroup) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	g.Handle(method, pattern, h)
+}
+
+// Get 注册 GET 路由.
+func (g *RouteGroup) Get(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodGet, pattern, h)
+}
+
+// Post 注册 POST 路由.
+func (g *RouteGroup) Post(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPost, pattern, h)
+}
+
+// Put 注册 PUT 路由.
+func (g *RouteGroup) Put(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPut, pattern, h)
+}
+
+// Patch 注册 PATCH 路由.
+func (g *RouteGroup) Patch(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodPatch, pattern, h)
+}
+
+// Delete 注册 DELETE 路由.
+func (g *RouteGroup) Delete(pattern string, h http.HandlerFunc) {
+	g.Handle(http.MethodDelete, pattern, h)
+}
+
+// insert 返回 segments 对应的节点, 不存在时创建.
+func (n *routeNode) insert(segments []string, pattern string) *routeNode {
+	for _, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name := seg[1 : len(seg)-1]
+			if name == "" {
+				panic(fmt.Sprintf("router: empty parameter name in %q", pattern))
+			}
+			if n.param == nil {
+				n.param = &routeNode{name: name}
+			} else if n.param.name != name {
+				panic(fmt.Sprintf("router: parameter {%s} in %q conflicts with {%s}", name, pattern, n.param.name))
+			}
+			n = n.param
+			continue
+		}
+		if n.static == nil {
+			n.static = make(map[string]*routeNode)
+		}
+		child, ok := n.static[seg]
+		if !ok {
+			child = &routeNode{}
+			n.static[seg] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// match 返回和 segments 匹配的节点和路径参数, 静态段优先, 不匹配时回退到参数.
+func (n *routeNode) match(segments []string) (*routeNode, []pathParam) {
+	if len(segments) == 0 {
+		if len(n.handlers) == 0 {
+			return nil, nil
+		}
+		return n, nil
+	}
+	seg := segments[0]
+	if child, ok := n.static[seg]; ok {
+		if node, params := child.match(segments[1:]); node != nil {
+			return node, params
+		}
+	}
+	if n.param != nil {
+		value, err := url.PathUnescape(seg)
+		if err != nil || value == "" {
+			return nil, nil
+		}
+		if node, params := n.param.match(segments[1:]); node != nil {
+			return node, append([]pathParam{{name: n.param.name, value: value}}, params...)
+		}
+	}
+	return nil, nil
+}
+
+// allowed 返回支持的方法, 包括自动支持的 HEAD 和 OPTIONS.
+func (n *routeNode) allowed() []string {
+	methods := make([]string, 0, len(n.handlers)+2)
+	for method := range n.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok {
+		if _, ok := n.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	if _, ok := n.handlers[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// splitPath 按 "/" 拆分路径, 忽略开头和末尾的 "/", "/" 返回空.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+type pathParamsKey struct{}
+
+type pathParam struct {
+	name, value string
+}
+
+// PathParam 返回 Router 匹配的路径参数, 例如 /users/{id} 中的 id, 已经过 URL 解码; 没有该参数时返回空字符串.
+func PathParam(ctx context.Context, name string) string {
+	params, _ := ctx.Value(pathParamsKey{}).([]pathParam)
+	for _, p := range params {
+		if p.name == name {
+			return p.value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echo 返回路由名称和路径参数.
+func echo(name string, params ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := []string{name}
+		for _, p := range params {
+			values = append(values, p+"="+PathParam(r.Context(), p))
+		}
+		w.Header().Set("X-Route", strings.Join(values, " "))
+		WriteSuccess(w, r, http.StatusOK, nil)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	r.Get("/", echo("root"))
+	r.Get("/users", echo("list"))
+	r.Post("/users", echo("create"))
+	r.Get("/users/me", echo("me"))
+	r.Get("/users/{id}", echo("get", "id"))
+	r.Delete("/users/{id}", echo("delete", "id"))
+	r.Get("/users/{id}/orders/{order}", echo("order", "id", "order"))
+	r.Get("/files/{name}", echo("file", "name"))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantRoute  string
+		wantAllow  string
+	}{
+		{name: "root", method: http.MethodGet, path: "/", wantStatus: http.StatusOK, wantRoute: "root"},
+		{name: "static", method: http.MethodGet, path: "/users", wantStatus: http.StatusOK, wantRoute: "list"},
+		{name: "trailing slash", method: http.MethodGet, path: "/users/", wantStatus: http.StatusOK, wantRoute: "list"},
+		{name: "method", method: http.MethodPost, path: "/users", wantStatus: http.StatusOK, wantRoute: "create"},
+		{name: "param", method: http.MethodGet, path: "/users/42", wantStatus: http.StatusOK, wantRoute: "get id=42"},
+		{name: "static before param", method: http.MethodGet, path: "/users/me", wantStatus: http.StatusOK, wantRoute: "me"},
+		{name: "param on static branch", method: http.MethodDelete, path: "/users/me", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "nested params", method: http.MethodGet, path: "/users/42/orders/7", wantStatus: http.StatusOK, wantRoute: "order id=42 order=7"},
+		{name: "escaped param", method: http.MethodGet, path: "/files/a%2Fb%20c", wantStatus: http.StatusOK, wantRoute: "file name=a/b c"},
+		{name: "head uses get", method: http.MethodHead, path: "/users/42", wantStatus: http.StatusOK, wantRoute: "get id=42"},
+		{name: "options", method: http.MethodOptions, path: "/users/42", wantStatus: http.StatusNoContent, wantAllow: "DELETE, GET, HEAD, OPTIONS"},
+		{name: "method not allowed", method: http.MethodPut, path: "/users", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS, POST"},
+		{name: "not found", method: http.MethodGet, path: "/orders", wantStatus: http.StatusNotFound},
+		{name: "too deep", method: http.MethodGet, path: "/users/42/orders", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v, body %q", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("X-Route"); got != tt.wantRoute {
+				t.Errorf("route = %q, want %q", got, tt.wantRoute)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouter_Middleware(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	r := NewRouter()
+	r.Use(mark("global"))
+	api := r.Group("/api/", mark("api"))
+	api.Group("/v1", mark("v1")).Get("/users/{id}", echo("get", "id"))
+	r.Get("/healthz", echo("health"))
+
+	tests := []struct {
+		path      string
+		wantOrder string
+		wantRoute string
+	}{
+		{path: "/api/v1/users/1", wantOrder: "global,api,v1", wantRoute: "get id=1"},
+		{path: "/healthz", wantOrder: "global", wantRoute: "health"},
+		// 全局 middleware 也作用于 404
+		{path: "/api/v2/users/1", wantOrder: "global"},
+	}
+	for _, tt := range tests {
+		order = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := strings.Join(order, ","); got != tt.wantOrder || w.Header().Get("X-Route") != tt.wantRoute {
+			t.Errorf("%s: order = %s, route = %q", tt.path, got, w.Header().Get("X-Route"))
+		}
+	}
+}
+
+func TestRouter_Conflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r *Router)
+	}{
+		{name: "duplicate", register: func(r *Router) { r.Get("/users", echo("a")); r.Get("/users/", echo("b")) }},
+		{name: "param names", register: func(r *Router) { r.Get("/users/{id}", echo("a")); r.Get("/users/{name}/x", echo("b")) }},
+		{name: "empty param", register: func(r *Router) { r.Get("/users/{}", echo("a")) }},
+		{name: "relative", register: func(r *Router) { r.Get("users", echo("a")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic")
+				}
+			}()
+			tt.register(NewRouter())
+		})
+	}
+}
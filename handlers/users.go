@@ -1,48 +1,302 @@
 package handlers
 
 import (
-	"demo-to-start/mysql"
+	"database/sql"
+	"demo-to-start/logger"
+	"demo-to-start/model"
+	"demo-to-start/trace"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
+// UserHandlerConfig 是用户相关 http 接口的配置.
+type UserHandlerConfig struct {
+	Users  model.UserRepository // 必须; 用户存储
+	Logger logger.Logger        // 可选; 日志, 默认 logger.Default()
+}
+
+// UserHandler 是用户相关的 http 接口.
+type UserHandler struct {
+	users  model.UserRepository
+	logger logger.Logger
+}
+
+// NewUserHandler 创建用户相关的 http 接口.
+func NewUserHandler(config UserHandlerConfig) (*UserHandler, error) {
+	if config.Users == nil {
+		return nil, errors.New("nil user repository")
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	return &UserHandler{users: config.Users, logger: config.Logger}, nil
+}
+
 type QueryUserRequest struct {
 	UserID int `json:"user_id"`
 }
+
 // QueryUser 请求处理逻辑
-func QueryUser(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) QueryUser(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.queryUser).ServeHTTP(w, r)
+}
+
+func (h *UserHandler) queryUser(w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.Start(r.Context(), "http.QueryUser", "http.method", r.Method, "http.path", r.URL.Path)
+	defer span.End()
+
 	bd, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println(err)
-		return
+		h.logger.Warn(ctx, "read-request-body-failed", "path", r.URL.Path, "error", err.Error())
+		return InvalidParam("request body is invalid")
 	}
 	var req QueryUserRequest
 	err = json.Unmarshal(bd, &req)
 	if err != nil {
-		log.Println(err)
-		return
+		h.logger.Warn(ctx, "unmarshal-request-failed", "path", r.URL.Path, "error", err.Error())
+		return InvalidParam("request body is invalid")
 	}
 	if req.UserID <= 0 {
-		_, _ = w.Write([]byte("userID is invalid"))
-		return
+		return InvalidParam("userID is invalid")
+	}
+	user, err := h.users.Get(ctx, req.UserID)
+	if err != nil {
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	WriteSuccess(w, r, http.StatusOK, user)
+	return nil
+}
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+	maxUserNameLen   = 64
+	maxUserAge       = 150
+)
+
+// UserRequest 是创建和更新用户的请求, PATCH 时没有传的字段保持不变.
+type UserRequest struct {
+	Name *string `json:"name"`
+	Age  *int    `json:"age"`
+}
+
+// ListUsersResponse 是分页查询用户的响应.
+type ListUsersResponse struct {
+	Users  []model.User
+	Offset int
+	Limit  int
+}
+
+// RegisterRoutes 注册用户接口:
+//
+//	GET    /users       分页查询用户
+//	POST   /users       创建用户
+//	GET    /users/{id}  查询用户
+//	PUT    /users/{id}  全量更新用户
+//	PATCH  /users/{id}  部分更新用户
+//	DELETE /users/{id}  删除用户
+func (h *UserHandler) RegisterRoutes(g *RouteGroup) {
+	g.Get("/users", h.ListUsers)
+	g.Post("/users", h.CreateUser)
+	g.Get("/users/{id}", h.GetUser)
+	g.Put("/users/{id}", h.UpdateUser)
+	g.Patch("/users/{id}", h.UpdateUser)
+	g.Delete("/users/{id}", h.DeleteUser)
+}
+
+// ListUsers 处理 GET /users?offset=0&limit=20.
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.listUsers).ServeHTTP(w, r)
+}
+
+// CreateUser 处理 POST /users.
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.createUser).ServeHTTP(w, r)
+}
+
+// GetUser 处理 GET /users/{id}.
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.getUser).ServeHTTP(w, r)
+}
+
+// UpdateUser 处理 PUT /users/{id} 全量更新和 PATCH /users/{id} 部分更新.
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.updateUser).ServeHTTP(w, r)
+}
+
+// DeleteUser 处理 DELETE /users/{id}.
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	HandlerFunc(h.deleteUser).ServeHTTP(w, r)
+}
+
+// userID 返回路径参数中的用户 id.
+func userID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(PathParam(r.Context(), "id"))
+	if err != nil || id <= 0 {
+		return 0, InvalidParam("userID is invalid")
 	}
-	user, err := mysql.GetUser(req.UserID)
+	return id, nil
+}
+
+func (h *UserHandler) listUsers(w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.Start(r.Context(), "http.ListUsers")
+	defer span.End()
+
+	offset, limit, err := parsePagination(r.URL.Query())
 	if err != nil {
-		log.Println(err)
-		_, _ = w.Write([]byte(err.Error()))
-		return
+		return err
 	}
-	resp := Response{Code: 1, Msg: "success", Data: user}
-	bd, err = json.Marshal(resp)
+	users, err := h.users.List(ctx, offset, limit)
 	if err != nil {
-		log.Println(err)
-		_, _ = w.Write([]byte(err.Error()))
-		return
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	WriteSuccess(w, r, http.StatusOK, ListUsersResponse{Users: users, Offset: offset, Limit: limit})
+	return nil
+}
+
+func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.Start(r.Context(), "http.CreateUser")
+	defer span.End()
+
+	var req UserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	var user model.User
+	if err := req.apply(&user, true); err != nil {
+		return err
+	}
+	user, err := h.users.Create(ctx, user)
+	if err != nil {
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	w.Header().Set("Location", "/users/"+strconv.Itoa(user.ID))
+	WriteSuccess(w, r, http.StatusCreated, user)
+	return nil
+}
+
+func (h *UserHandler) getUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userID(r)
+	if err != nil {
+		return err
+	}
+	ctx, span := trace.Start(r.Context(), "http.GetUser", "user_id", id)
+	defer span.End()
+
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	WriteSuccess(w, r, http.StatusOK, user)
+	return nil
+}
+
+func (h *UserHandler) updateUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userID(r)
+	if err != nil {
+		return err
+	}
+	ctx, span := trace.Start(r.Context(), "http.UpdateUser", "user_id", id)
+	defer span.End()
+
+	var req UserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	user := model.User{ID: id}
+	if r.Method == http.MethodPatch {
+		var err error
+		if user, err = h.users.Get(ctx, id); err != nil {
+			span.RecordError(err)
+			return h.storageError(r.WithContext(ctx), err)
+		}
+	}
+	if err := req.apply(&user, r.Method == http.MethodPut); err != nil {
+		return err
+	}
+	if err := h.users.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	WriteSuccess(w, r, http.StatusOK, user)
+	return nil
+}
+
+func (h *UserHandler) deleteUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userID(r)
+	if err != nil {
+		return err
+	}
+	ctx, span := trace.Start(r.Context(), "http.DeleteUser", "user_id", id)
+	defer span.End()
+
+	if err := h.users.Delete(ctx, id); err != nil {
+		span.RecordError(err)
+		return h.storageError(r.WithContext(ctx), err)
+	}
+	WriteSuccess(w, r, http.StatusOK, nil)
+	return nil
+}
+
+// apply 校验请求并更新 user, required 为 true 时所有字段都必须传.
+func (req UserRequest) apply(user *model.User, required bool) error {
+	if required && (req.Name == nil || req.Age == nil) {
+		return InvalidParam("name and age are required")
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > maxUserNameLen {
+			return InvalidParam("name is invalid")
+		}
+		user.Name = name
+	}
+	if req.Age != nil {
+		if *req.Age < 0 || *req.Age > maxUserAge {
+			return InvalidParam("age is invalid")
+		}
+		user.Age = *req.Age
+	}
+	return nil
+}
+
+// parsePagination 解析 offset 和 limit 查询参数, limit 默认 20, 最大 100.
+func parsePagination(query url.Values) (offset, limit int, err error) {
+	limit = defaultListLimit
+	if s := query.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			return 0, 0, InvalidParam("offset is invalid")
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 || limit > maxListLimit {
+			return 0, 0, InvalidParam("limit is invalid")
+		}
+	}
+	return offset, limit, nil
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return InvalidParam("request body is invalid")
+	}
+	return nil
+}
+
+// storageError 把存储的错误转换为返回给客户端的错误, 内部错误只打印日志, 不返回具体原因.
+func (h *UserHandler) storageError(r *http.Request, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("user not found")
 	}
-	// 设置响应头
-	w.Header().Add("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(bd)
+	h.logger.Error(r.Context(), "user-storage-failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	return err
 }
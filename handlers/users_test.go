@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"demo-to-start/logger"
+	"demo-to-start/memstore"
+	"demo-to-start/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestUserHandler(t *testing.T) *UserHandler {
+	t.Helper()
+	h, err := NewUserHandler(UserHandlerConfig{Users: memstore.NewUserRepository(model.User{ID: 1, Name: "alice", Age: 18})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestUserHandler_QueryUser(t *testing.T) {
+	h := newTestUserHandler(t)
+
+	w := httptest.NewRecorder()
+	h.QueryUser(w, httptest.NewRequest(http.MethodPost, "/get_user", strings.NewReader(`{"user_id": 1}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Code int
+		Data model.User
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	if resp.Code != 1 || resp.Data != (model.User{ID: 1, Name: "alice", Age: 18}) {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestUserHandler_QueryUser_Invalid(t *testing.T) {
+	h := newTestUserHandler(t)
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   int
+	}{
+		{name: "invalid body", body: `{"user_id":`, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidParam},
+		{name: "invalid id", body: `{"user_id": 0}`, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidParam},
+		{name: "not found", body: `{"user_id": 2}`, wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.QueryUser(w, httptest.NewRequest(http.MethodPost, "/get_user", strings.NewReader(tt.body)))
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.wantStatus || resp.Code != tt.wantCode || resp.Msg == "" {
+				t.Errorf("status = %v, response = %+v, want %v, code %v", w.Code, resp, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+type failingUserRepository struct {
+	model.UserRepository
+}
+
+func (failingUserRepository) Get(context.Context, int) (model.User, error) {
+	return model.User{}, errors.New("dial tcp 10.0.0.1:3306: connect: connection refused")
+}
+
+func TestUserHandler_QueryUser_InternalError(t *testing.T) {
+	h, err := NewUserHandler(UserHandlerConfig{Users: failingUserRepository{}, Logger: logger.Nop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.QueryUser(w, httptest.NewRequest(http.MethodPost, "/get_user", strings.NewReader(`{"user_id": 1}`)))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %v, want %v", w.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(w.Body.String(), "10.0.0.1") {
+		t.Errorf("internal error leaked: %q", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"Code":50000`) {
+		t.Errorf("body = %q, want code %v", w.Body.String(), CodeInternal)
+	}
+}
+
+func TestNewUserHandler(t *testing.T) {
+	if _, err := NewUserHandler(UserHandlerConfig{}); err == nil {
+		t.Errorf("NewUserHandler() error = nil, want error")
+	}
+}
+
+func TestUserHandler_REST(t *testing.T) {
+	h := newTestUserHandler(t)
+	mux := NewRouter()
+	h.RegisterRoutes(mux.Group(""))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "get", method: http.MethodGet, path: "/users/1", wantStatus: http.StatusOK, wantBody: `"Name":"alice"`},
+		{name: "get not found", method: http.MethodGet, path: "/users/9", wantStatus: http.StatusNotFound},
+		{name: "get invalid id", method: http.MethodGet, path: "/users/abc", wantStatus: http.StatusBadRequest},
+		{name: "head", method: http.MethodHead, path: "/users/1", wantStatus: http.StatusOK},
+		{name: "create", method: http.MethodPost, path: "/users", body: `{"name":"bob","age":20}`, wantStatus: http.StatusCreated, wantBody: `"ID":2`},
+		{name: "create invalid", method: http.MethodPost, path: "/users", body: `{"name":"","age":20}`, wantStatus: http.StatusBadRequest},
+		{name: "create missing age", method: http.MethodPost, path: "/users", body: `{"name":"bob"}`, wantStatus: http.StatusBadRequest},
+		{name: "list", method: http.MethodGet, path: "/users?offset=1&limit=1", wantStatus: http.StatusOK, wantBody: `"Name":"bob"`},
+		{name: "list invalid limit", method: http.MethodGet, path: "/users?limit=1000", wantStatus: http.StatusBadRequest},
+		{name: "patch", method: http.MethodPatch, path: "/users/1", body: `{"age":19}`, wantStatus: http.StatusOK, wantBody: `"Name":"alice","Age":19`},
+		{name: "put requires all fields", method: http.MethodPut, path: "/users/1", body: `{"age":19}`, wantStatus: http.StatusBadRequest},
+		{name: "put not found", method: http.MethodPut, path: "/users/9", body: `{"name":"x","age":1}`, wantStatus: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, path: "/users/2", wantStatus: http.StatusOK},
+		{name: "delete again", method: http.MethodDelete, path: "/users/2", wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodDelete, path: "/users", wantStatus: http.StatusMethodNotAllowed},
+		{name: "wrong method on item", method: http.MethodPost, path: "/users/1", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v, body %q", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want contains %q", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed && w.Header().Get("Allow") == "" {
+				t.Errorf("missing Allow header")
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("Content-Type = %q, want json", ct)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Package health 汇总依赖 (数据库, kafka, 缓存等) 的检查结果, 用于 /readyz 等就绪探测.
+//
+// 探测频率通常比依赖能承受的高, 检查结果缓存 Interval 之后才重新检查. 开始停止之后不再检查, 直接返回未就绪,
+// 让负载均衡在 http server 停止之前摘除流量.
+package health
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+// Status 是检查结果.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// ErrShuttingDown 是开始停止之后的检查错误.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Checker 检查一个依赖是否可用, 不可用时返回错误. ctx 带有检查的超时时间.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc 把函数适配为 Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (fn CheckerFunc) Check(ctx context.Context) error { return fn(ctx) }
+
+// Pinger 是可以 ping 的连接, 例如 *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping 返回 ping 数据库的 Checker.
+func Ping(db Pinger) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// Result 是一个 Checker 的检查结果.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Latency string `json:"latency"`         // 检查耗时, 例如 "1.5ms"
+	Error   string `json:"error,omitempty"` // 不可用的原因
+}
+
+// Report 是所有 Checker 的检查结果, 全部可用时 Status 为 StatusUp.
+type Report struct {
+	Status    Status    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Config 是 Readiness 相关配置.
+type Config struct {
+	Interval      time.Duration // 可选; 检查结果的缓存时间, 期间的探测直接返回上次的结果, 默认 5s
+	Timeout       time.Duration // 可选; 单个 Checker 的超时时间, 默认 2s
+	ShutdownDelay time.Duration // 可选; Shutdown 变为未就绪之后等待的时间, 让负载均衡发现并摘除流量, 默认 0
+	Logger        logger.Logger // 可选; 日志, 默认 logger.Default()
+}
+
+// Readiness 并发执行注册的 Checker, 汇总为 Report. 实现 app.Component, 注册在 http server 之后,
+// 停止时最先变为未就绪.
+type Readiness struct {
+	interval      time.Duration
+	timeout       time.Duration
+	shutdownDelay time.Duration
+	logger        logger.Logger
+	now           func() time.Time
+	shutdown      common.Bool
+
+	mu       sync.Mutex
+	names    []string
+	checkers map[string]Checker
+	last     *Report
+	running  *checkRun // 正在进行的检查, 并发的探测等待这次的结果, 不会重复检查
+}
+
+// checkRun 是一次正在进行的检查, 结束时关闭 done.
+type checkRun struct {
+	done   chan struct{}
+	report Report
+}
+
+// NewReadiness 创建 Readiness.
+func NewReadiness(config Config) *Readiness {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	return &Readiness{
+		interval:      config.Interval,
+		timeout:       config.Timeout,
+		shutdownDelay: config.ShutdownDelay,
+		logger:        config.Logger,
+		now:           time.Now,
+		checkers:      make(map[string]Checker),
+	}
+}
+
+// Register 注册 Checker, 结果按注册顺序排列, name 不能重复.
+func (r *Readiness) Register(name string, checker Checker) error {
+	if name == "" {
+		return errors.New("empty checker name")
+	}
+	if checker == nil {
+		return fmt.Errorf("nil checker %s", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checkers[name]; ok {
+		return fmt.Errorf("duplicate checker %s", name)
+	}
+	r.names = append(r.names, name)
+	r.checkers[name] = checker
+	r.last = nil
+	r.running = nil
+	return nil
+}
+
+// Check 返回检查结果, 上次检查在 Interval 之内时直接返回上次的结果, 正在检查时等待这次的结果.
+// 开始停止之后不再检查, 返回未就绪.
+//
+// 检查不受 ctx 取消的影响, 只有 Timeout 的限制: 探测方断开或者超时的时候, 检查继续进行并缓存结果,
+// 这次探测返回 ctx 的错误, 不会缓存.
+func (r *Readiness) Check(ctx context.Context) Report {
+	r.mu.Lock()
+	now := r.now()
+	if r.shutdown.Load() {
+		defer r.mu.Unlock()
+		return r.down(now, ErrShuttingDown)
+	}
+	if r.last != nil && now.Sub(r.last.CheckedAt) < r.interval {
+		defer r.mu.Unlock()
+		return *r.last
+	}
+	run := r.running
+	if run == nil {
+		run = &checkRun{done: make(chan struct{})}
+		r.running = run
+		names := append([]string(nil), r.names...)
+		checkers := make([]Checker, len(names))
+		for i, name := range names {
+			checkers[i] = r.checkers[name]
+		}
+		go r.runChecks(context.WithoutCancel(ctx), run, now, names, checkers)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-run.done:
+		return run.report
+	case <-ctx.Done():
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.down(now, ctx.Err())
+	}
+}
+
+// runChecks 并发执行 checkers, 结束之后缓存结果并关闭 run.done.
+func (r *Readiness) runChecks(ctx context.Context, run *checkRun, now time.Time, names []string, checkers []Checker) {
+	report := Report{Status: StatusUp, CheckedAt: now, Checks: make([]Result, len(names))}
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = r.check(ctx, names[i], checkers[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+			r.logger.Warn(ctx, "readiness-check-failed", "check", result.Name, "error", result.Error)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 期间注册了新的 Checker 时结果不完整, 不缓存
+	if r.running == run {
+		r.running = nil
+		r.last = &report
+	}
+	run.report = report
+	close(run.done)
+}
+
+func (r *Readiness) check(ctx context.Context, name string, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	begin := time.Now()
+	err := checker.Check(ctx)
+	result := Result{Name: name, Status: StatusUp, Latency: time.Since(begin).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// down 返回所有 Checker 都因为 err 未就绪的结果, 用于停止期间和探测方的 ctx 结束, 调用方持有锁.
+func (r *Readiness) down(now time.Time, err error) Report {
+	report := Report{Status: StatusDown, CheckedAt: now, Checks: make([]Result, len(r.names))}
+	for i, name := range r.names {
+		report.Checks[i] = Result{Name: name, Status: StatusDown, Latency: "0s", Error: err.Error()}
+	}
+	return report
+}
+
+// ShuttingDown 返回是否已经开始停止.
+func (r *Readiness) ShuttingDown() bool {
+	return r.shutdown.Load()
+}
+
+// Start 实现 app.Component, 没有需要启动的资源.
+func (r *Readiness) Start(context.Context) error { return nil }
+
+// Shutdown 变为未就绪, 等待 ShutdownDelay 之后返回, 之后的组件 (例如 http server) 才开始停止.
+func (r *Readiness) Shutdown(ctx context.Context) error {
+	r.shutdown.Store(true)
+	if r.shutdownDelay <= 0 {
+		return nil
+	}
+	r.logger.Info(ctx, "readiness-shutdown-delay", "delay", r.shutdownDelay.String())
+	select {
+	case <-time.After(r.shutdownDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
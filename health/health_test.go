@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestReadiness(config Config) (*Readiness, *time.Time) {
+	config.Logger = logger.Nop()
+	r := NewReadiness(config)
+	now := time.Unix(1600000000, 0)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestReadiness_Check(t *testing.T) {
+	ctx := context.Background()
+	r, now := newTestReadiness(Config{Interval: time.Second})
+	var calls common.Int32
+	var fail common.Error
+	_ = r.Register("mysql", CheckerFunc(func(context.Context) error {
+		calls.Inc()
+		return fail.Load()
+	}))
+	_ = r.Register("cache", CheckerFunc(func(context.Context) error { return nil }))
+
+	report := r.Check(ctx)
+	if report.Status != StatusUp || len(report.Checks) != 2 || report.Checks[0].Name != "mysql" || report.Checks[1].Name != "cache" {
+		t.Fatalf("Check() = %+v", report)
+	}
+
+	// Interval 之内返回缓存的结果
+	fail.Store(errors.New("connection refused"))
+	if report := r.Check(ctx); report.Status != StatusUp || calls.Load() != 1 {
+		t.Errorf("cached Check() = %+v, calls = %d", report, calls.Load())
+	}
+
+	*now = now.Add(time.Second)
+	report = r.Check(ctx)
+	if report.Status != StatusDown || calls.Load() != 2 {
+		t.Fatalf("Check() = %+v, calls = %d", report, calls.Load())
+	}
+	if got := report.Checks[0]; got.Status != StatusDown || got.Error != "connection refused" || got.Latency == "" {
+		t.Errorf("Checks[0] = %+v", got)
+	}
+	if got := report.Checks[1]; got.Status != StatusUp || got.Error != "" {
+		t.Errorf("Checks[1] = %+v", got)
+	}
+}
+
+func TestReadiness_Timeout(t *testing.T) {
+	r, _ := newTestReadiness(Config{Timeout: 10 * time.Millisecond})
+	_ = r.Register("kafka", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	report := r.Check(context.Background())
+	if report.Status != StatusDown || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Check() = %+v", report)
+	}
+}
+
+func TestReadiness_ProbeCanceled(t *testing.T) {
+	r, _ := newTestReadiness(Config{})
+	var calls common.Int32
+	release := make(chan struct{})
+	_ = r.Register("kafka", CheckerFunc(func(ctx context.Context) error {
+		calls.Inc()
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}))
+
+	// 探测方超时返回 ctx 的错误, 检查继续进行
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if report := r.Check(ctx); report.Status != StatusDown || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("Check() with canceled ctx = %+v", report)
+	}
+	close(release)
+	// 之后的探测等待同一次检查的结果, 不缓存探测方的错误
+	for i := 0; i < 2; i++ {
+		if report := r.Check(context.Background()); report.Status != StatusUp || calls.Load() != 1 {
+			t.Errorf("Check() = %+v, calls = %d", report, calls.Load())
+		}
+	}
+}
+
+func TestReadiness_Concurrent(t *testing.T) {
+	r, _ := newTestReadiness(Config{})
+	var calls common.Int32
+	release := make(chan struct{})
+	_ = r.Register("kafka", CheckerFunc(func(context.Context) error {
+		calls.Inc()
+		<-release
+		return nil
+	}))
+
+	const probes = 5
+	reports := make(chan Report, probes)
+	for i := 0; i < probes; i++ {
+		go func() { reports <- r.Check(context.Background()) }()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < probes; i++ {
+		if report := <-reports; report.Status != StatusUp {
+			t.Errorf("Check() = %+v", report)
+		}
+	}
+	// 并发的探测共用一次检查
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestReadiness_Shutdown(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestReadiness(Config{ShutdownDelay: 10 * time.Millisecond})
+	var calls common.Int32
+	_ = r.Register("mysql", CheckerFunc(func(context.Context) error {
+		calls.Inc()
+		return nil
+	}))
+	if report := r.Check(ctx); report.Status != StatusUp {
+		t.Fatalf("Check() = %+v", report)
+	}
+
+	begin := time.Now()
+	if err := r.Shutdown(ctx); err != nil || time.Since(begin) < 10*time.Millisecond {
+		t.Errorf("Shutdown() = %v after %v", err, time.Since(begin))
+	}
+	// 停止期间不再检查, 也不返回缓存的结果
+	report := r.Check(ctx)
+	if report.Status != StatusDown || report.Checks[0].Error != ErrShuttingDown.Error() || calls.Load() != 1 || !r.ShuttingDown() {
+		t.Errorf("Check() after Shutdown = %+v, calls = %d", report, calls.Load())
+	}
+}
+
+func TestReadiness_Register(t *testing.T) {
+	r, _ := newTestReadiness(Config{})
+	if err := r.Register("mysql", CheckerFunc(func(context.Context) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	for name, checker := range map[string]Checker{"mysql": CheckerFunc(func(context.Context) error { return nil }), "": CheckerFunc(nil), "kafka": nil} {
+		if err := r.Register(name, checker); err == nil {
+			t.Errorf("Register(%q) error = nil", name)
+		}
+	}
+}
@@ -22,19 +22,25 @@ type Producer interface {
 	// ⚠️注意: message.MessageType 和 proto.Message 要匹配.
 	SendMessage(context.Context, MessageType, proto.Message, ...SendMessageOption) error
 
-	// Close 关闭 Producer, 释放相关资源, 防止资源泄漏.
+	// Close 关闭 Producer, 释放相关资源, 防止资源泄漏. 可以重复调用, 关闭之后 SendMessage 返回错误.
 	Close(context.Context) error
 }
 
 // Consumer 是消息总线的消费者接口.
 type Consumer interface {
 	// StartConsumeMessage 启动消费消息总线上的消息.
-	//  StartConsumeMessage 会阻塞当前的 goroutine, 直到 Close 方法被调用了.
+	//  StartConsumeMessage 会阻塞当前的 goroutine, 直到 Close 方法被调用了或者 ctx 结束.
+	//  只能调用一次, 重复调用或者 Close 之后调用返回的错误满足 errors.Is(err, common.ErrInvalidState).
 	StartConsumeMessage(_ context.Context, handlers map[MessageType]MessageHandler) error
 
+	// Check 检查 broker 是否可以连接, 以及是否已经加入 consumer group, 用于就绪探测.
+	//  StartConsumeMessage 之前, Close 之后以及 rebalance 期间返回错误.
+	Check(context.Context) error
+
 	// Close 停止消费消息, 关闭 Consumer, 释放相关资源, 防止资源泄漏.
 	//
 	// ⚠️注意: 即使没有调用 StartConsumeMessage 也需要调用这个方法, 否则有资源泄漏.
+	// 可以重复调用, 都返回第一次关闭的结果.
 	Close(context.Context) error
 }
 
@@ -94,3 +100,41 @@ func Unmarshal(buf []byte, pb proto.Message) error {
 		AllowPartial: true, // syntax = "proto3";
 	}.Unmarshal(buf, pb)
 }
+
+// producerHeaderCarrier 把 kafka 生产者的消息头适配为 trace.Carrier.
+type producerHeaderCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerHeaderCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerHeaderCarrier) Set(key, value string) {
+	for i := range c.msg.Headers {
+		if string(c.msg.Headers[i].Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// consumerHeaderCarrier 把 kafka 消费者的消息头适配为 trace.Carrier, 只读.
+type consumerHeaderCarrier []*sarama.RecordHeader
+
+func (c consumerHeaderCarrier) Get(key string) string {
+	for _, h := range c {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c consumerHeaderCarrier) Set(string, string) {}
@@ -3,16 +3,21 @@ package kafka
 import (
 	"context"
 	"demo-to-start/common"
+	"demo-to-start/logger"
+	"demo-to-start/trace"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/Shopify/sarama"
-	"log"
-	"strconv"
-	"strings"
-	"sync"
+	"regexp"
+	"slices"
+	"sort"
 	"time"
 )
 
+// consumeRetryInterval 是消费失败之后重试的间隔.
+const consumeRetryInterval = time.Second
+
 // ConsumerConfig 是 kafka consumer 相关配置
 type ConsumerConfig struct {
 	Brokers           []string // 必须; kafka brokers
@@ -23,6 +28,18 @@ type ConsumerConfig struct {
 	User              string   // 可选; kafka 用户名
 	Password          string   // 可选; kafka 密码
 	ChannelBufferSize int      // 可选; partition consumer 缓存大小
+
+	TopicNamer           TopicNamer    // 可选; topic 命名规则, 默认 DefaultTopicNamer, 需要和 producer 保持一致
+	TopicPattern         string        // 可选; 额外订阅名称匹配该正则的 topics, 按 TopicNamer 解析出 MessageType
+	TopicRefreshInterval time.Duration // 可选; 配置了 TopicPattern 时刷新 topics 的间隔, 默认 1 分钟
+
+	// 可选; 每次加入 consumer group 并分配 partitions 之后, 开始消费之前调用, 包括启动, rebalance 和重连
+	OnAssigned func(ctx context.Context)
+	// 可选; 为 true 时 Close 删除 consumer group 和提交的 offsets, 用于每次启动都不同的临时 group, 需要 kafka 1.1 以上
+	DeleteGroupOnClose bool
+
+	Logger logger.Logger // 可选; 日志, 默认 logger.Default()
+	Tracer *trace.Tracer // 可选; 链路追踪, 默认 trace.Default(), 延续消息头中的 trace context
 }
 
 // NewKafkaConsumer 创建一个新的 kafka Consumer.
@@ -57,6 +74,30 @@ func NewKafkaConsumer(config ConsumerConfig) (Consumer, error) {
 		return nil, errors.New("empty group")
 	}
 
+	if config.TopicNamer == nil {
+		config.TopicNamer = DefaultTopicNamer
+	}
+
+	var topicPattern *regexp.Regexp
+	if config.TopicPattern != "" {
+		p, err := regexp.Compile(config.TopicPattern)
+		if err != nil {
+			return nil, errors.New("invalid topic pattern")
+		}
+		topicPattern = p
+	}
+	if config.TopicRefreshInterval <= 0 {
+		config.TopicRefreshInterval = time.Minute
+	}
+
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+
+	if config.Tracer == nil {
+		config.Tracer = trace.Default()
+	}
+
 	kafkaConfig := sarama.NewConfig()
 	{
 		kafkaConfig.Version = kafkaVersion
@@ -90,20 +131,41 @@ func NewKafkaConsumer(config ConsumerConfig) (Consumer, error) {
 		}
 	}
 
-	consumerGroup, err := sarama.NewConsumerGroup(config.Brokers, config.Group, kafkaConfig)
+	client, err := sarama.NewClient(config.Brokers, kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(config.Group, client)
 	if err != nil {
+		_ = client.Close()
 		return nil, err
 	}
 
 	consumer := &kafkaConsumer{
-		consumerGroup: consumerGroup,
-		closing:       make(chan struct{}),
+		client:               client,
+		consumerGroup:        consumerGroup,
+		group:                config.Group,
+		topicNamer:           config.TopicNamer,
+		topicPattern:         topicPattern,
+		topicRefreshInterval: config.TopicRefreshInterval,
+		logger:               config.Logger,
+		tracer:               config.Tracer,
+		onAssigned:           config.OnAssigned,
+	}
+	if config.DeleteGroupOnClose {
+		group := config.Group
+		consumer.deleteGroup = func() error {
+			// admin 和 consumer 共用 client, 不能关闭 admin, 否则会关闭 client
+			admin, err := sarama.NewClusterAdminFromClient(client)
+			if err != nil {
+				return err
+			}
+			return admin.DeleteConsumerGroup(group)
+		}
 	}
 
-	// track errors
-	consumer.wg.Add(1)
-	go func(consumer *kafkaConsumer) {
-		defer consumer.wg.Done()
+	// track errors, consumerGroup 关闭之后 Errors 关闭, goroutine 退出
+	consumer.lifecycle.Go(func() {
 		for err := range consumer.consumerGroup.Errors() {
 			var (
 				consumerError    sarama.ConsumerError
@@ -116,12 +178,12 @@ func NewKafkaConsumer(config ConsumerConfig) (Consumer, error) {
 				insideError = consumerError.Err
 			}
 			if insideError != nil && errors.Is(insideError, sarama.ErrRequestTimedOut) {
-				log.Println(context.Background(), "got-kafka-consume-error", "error", err.Error())
+				consumer.logger.Warn(context.Background(), "got-kafka-consume-error", "error", err.Error())
 				continue
 			}
-			log.Println(context.Background(), "got-kafka-consume-error", "error", err.Error())
+			consumer.logger.Error(context.Background(), "got-kafka-consume-error", "error", err.Error())
 		}
-	}(consumer)
+	})
 
 	return consumer, nil
 }
@@ -132,61 +194,201 @@ var (
 )
 
 type kafkaConsumer struct {
+	client        sarama.Client
 	consumerGroup sarama.ConsumerGroup
-
-	started common.Bool    // 是否已经启动
-	closed  common.Bool    // 是否已经关闭
-	closing chan struct{}  // 关闭信号
-	wg      sync.WaitGroup // 关闭之后的 sync.WaitGroup
+	group         string
+	memberID      common.String // 加入 consumer group 之后的 member id, rebalance 期间和退出之后为空
+
+	topicNamer           TopicNamer
+	topicPattern         *regexp.Regexp
+	topicRefreshInterval time.Duration
+	logger               logger.Logger
+	tracer               *trace.Tracer
+	onAssigned           func(ctx context.Context)
+	deleteGroup          func() error // Close 时删除 consumer group, 为 nil 时不删除
+
+	// StartConsumeMessage 时 Starting → Running, Close 时 Stopping → Stopped, Done 是关闭信号
+	lifecycle common.Lifecycle
 }
 
+// Close 停止消费并释放资源, 可以重复调用, 都返回第一次关闭的结果.
 func (impl *kafkaConsumer) Close(ctx context.Context) error {
-	if !impl.closed.CompareAndSwap(false, true) {
-		return errors.New("the consumer close method has been called")
-	}
-	close(impl.closing)
-	if err := impl.consumerGroup.Close(); err != nil {
-		log.Println(ctx, "kafka-consumer-close-failed", "error", err.Error())
-		impl.wg.Wait()
+	return impl.lifecycle.Stop(ctx, func(ctx context.Context) error {
+		err := impl.consumerGroup.Close()
+		if err != nil {
+			impl.logger.Error(ctx, "kafka-consumer-close-failed", "error", err.Error())
+		}
+		// 等待错误日志的 goroutine 退出之后才能关闭 client
+		if werr := impl.lifecycle.Wait(ctx); err == nil {
+			err = werr
+		}
+		// 离开 consumer group 之后 group 为空才能删除
+		if impl.deleteGroup != nil {
+			if derr := impl.deleteGroup(); derr != nil {
+				impl.logger.Error(ctx, "kafka-delete-consumer-group-failed", "group", impl.group, "error", derr.Error())
+				if err == nil {
+					err = derr
+				}
+			}
+		}
+		if cerr := impl.client.Close(); cerr != nil {
+			impl.logger.Error(ctx, "kafka-client-close-failed", "error", cerr.Error())
+			if err == nil {
+				err = cerr
+			}
+		}
+		if err == nil {
+			impl.logger.Info(ctx, "kafka-consumer-closed")
+		}
 		return err
-	}
-	impl.wg.Wait()
-	log.Println(ctx, "kafka-consumer-closed")
-	return nil
+	})
 }
 
 func (impl *kafkaConsumer) StartConsumeMessage(ctx context.Context, handlers map[MessageType]MessageHandler) error {
-	if !impl.started.CompareAndSwap(false, true) {
-		return errors.New("the consumer has been started")
-	}
-
 	if len(handlers) == 0 {
 		return errors.New("empty handlers")
 	}
-
-	var groupHandler sarama.ConsumerGroupHandler = &consumerGroupHandler{
-		handlers: handlers,
+	if err := impl.lifecycle.Start(nil); err != nil {
+		return fmt.Errorf("start kafka consumer: %w", err)
 	}
+	done := impl.lifecycle.Done()
 
-	// 确定需要消费的 topics
-	topics := make([]string, 0, len(handlers))
-	for msgType := range handlers {
-		topics = append(topics, kafkaTopicFromMsgType(msgType))
+	var groupHandler sarama.ConsumerGroupHandler = &consumerGroupHandler{
+		handlers:   handlers,
+		topicNamer: impl.topicNamer,
+		logger:     impl.logger,
+		tracer:     impl.tracer,
+		memberID:   &impl.memberID,
+		onAssigned: impl.onAssigned,
 	}
 
 	for {
 		select {
-		case <-impl.closing:
+		case <-done:
 			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		default:
 		}
 
-		err := impl.consumerGroup.Consume(ctx, topics, groupHandler)
+		// 确定需要消费的 topics
+		topics, err := impl.subscribedTopics(handlers)
+		if err != nil {
+			impl.logger.Error(ctx, "kafka-list-topics-failed", "error", err.Error())
+		}
+
+		err = impl.consume(ctx, topics, handlers, groupHandler)
+		if err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			impl.logger.Error(ctx, "kafka-consume-failed", "topics", topics, "error", err.Error())
+			// 失败之后等待一段时间再重试, 防止 broker 不可用时空转
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(consumeRetryInterval):
+			}
+		}
+	}
+}
+
+// Check 检查 broker 是否可以连接, 以及是否已经加入 consumer group, 用于就绪探测.
+func (impl *kafkaConsumer) Check(ctx context.Context) error {
+	if state := impl.lifecycle.State(); state != common.StateRunning {
+		return &common.StateError{Op: "check kafka consumer", State: state}
+	}
+	// RefreshMetadata 不支持 ctx, 超时之后不再等待, 由 sarama 自己的超时结束
+	errc := make(chan error, 1)
+	go func() { errc <- impl.client.RefreshMetadata() }()
+	select {
+	case err := <-errc:
 		if err != nil {
-			log.Println(ctx, "kafka-consume-failed", "topics", topics, "error", err.Error())
-			continue
+			return fmt.Errorf("kafka brokers unreachable: %w", err)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("kafka brokers unreachable: %w", ctx.Err())
+	}
+	if impl.memberID.Load() == "" {
+		return fmt.Errorf("not a member of consumer group %s", impl.group)
+	}
+	return nil
+}
+
+// consume 消费 topics 直到 rebalance 或者订阅的 topics 发生变化 (TopicPattern 匹配到新的 topic 或者 topic 被删除).
+func (impl *kafkaConsumer) consume(ctx context.Context, topics []string, handlers map[MessageType]MessageHandler, handler sarama.ConsumerGroupHandler) error {
+	if impl.topicPattern == nil {
+		return impl.consumerGroup.Consume(ctx, topics, handler)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(impl.topicRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			latest, err := impl.subscribedTopics(handlers)
+			if err != nil {
+				impl.logger.Error(ctx, "kafka-list-topics-failed", "error", err.Error())
+				continue
+			}
+			if !slices.Equal(latest, topics) {
+				cancel() // 有新的 topic 或者 topic 被删除, 重新订阅
+				return
+			}
 		}
+	}()
+	return impl.consumerGroup.Consume(ctx, topics, handler)
+}
+
+// subscribedTopics 返回 handlers 对应的 topics 以及 TopicPattern 匹配的 topics, 结果已排序.
+func (impl *kafkaConsumer) subscribedTopics(handlers map[MessageType]MessageHandler) ([]string, error) {
+	set := make(map[string]struct{}, len(handlers))
+	for msgType := range handlers {
+		set[impl.topicNamer.Topic(msgType)] = struct{}{}
 	}
+	matched, err := impl.matchedTopics()
+	for _, topic := range matched {
+		set[topic] = struct{}{}
+	}
+
+	topics := make([]string, 0, len(set))
+	for topic := range set {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics, err
+}
+
+// matchedTopics 返回集群中名称匹配 TopicPattern 的 topics.
+func (impl *kafkaConsumer) matchedTopics() ([]string, error) {
+	if impl.topicPattern == nil {
+		return nil, nil
+	}
+	if err := impl.client.RefreshMetadata(); err != nil {
+		return nil, err
+	}
+	all, err := impl.client.Topics()
+	if err != nil {
+		return nil, err
+	}
+	var topics []string
+	for _, topic := range all {
+		if impl.topicPattern.MatchString(topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
 }
 
 /**************************************** implements sarama.ConsumerGroupHandler ****************************************/
@@ -194,11 +396,27 @@ func (impl *kafkaConsumer) StartConsumeMessage(ctx context.Context, handlers map
 var _ sarama.ConsumerGroupHandler = (*consumerGroupHandler)(nil)
 
 type consumerGroupHandler struct {
-	handlers map[MessageType]MessageHandler
+	handlers   map[MessageType]MessageHandler
+	topicNamer TopicNamer
+	logger     logger.Logger
+	tracer     *trace.Tracer
+	memberID   *common.String // 指向 kafkaConsumer.memberID
+	onAssigned func(ctx context.Context)
+}
+
+func (impl *consumerGroupHandler) Setup(ss sarama.ConsumerGroupSession) error {
+	impl.memberID.Store(ss.MemberID())
+	if impl.onAssigned != nil {
+		impl.onAssigned(ss.Context())
+	}
+	return nil
+}
+
+func (impl *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error {
+	impl.memberID.Store("")
+	return nil
 }
 
-func (impl *consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (impl *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 func (impl *consumerGroupHandler) ConsumeClaim(ss sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
 		err := impl.handleMessage(msg)
@@ -208,28 +426,21 @@ func (impl *consumerGroupHandler) ConsumeClaim(ss sarama.ConsumerGroupSession, c
 	return nil
 }
 
-func msgTypeFromKafkaTopic(topic string) (MessageType, bool) {
-	if !strings.HasPrefix(topic, kafkaTopicPrefix) {
-		return 0, false
-	}
-	n, err := strconv.ParseInt(topic[len(kafkaTopicPrefix):], 10, 32)
-	if err != nil {
-		return 0, false
-	}
-	return MessageType(n), true
-}
-
 func (impl *consumerGroupHandler) handleMessage(msg *sarama.ConsumerMessage) error {
-	msgType, ok := msgTypeFromKafkaTopic(msg.Topic)
+	ctx := trace.Extract(context.Background(), consumerHeaderCarrier(msg.Headers))
+	ctx, span := impl.tracer.Start(ctx, "kafka.consume", "msg_topic", msg.Topic, "msg_partition", msg.Partition, "msg_offset", msg.Offset)
+	defer span.End()
+
+	msgType, ok := impl.topicNamer.MessageType(msg.Topic)
 	if !ok {
-		log.Println("unexpected-topic", "msg-value", string(msg.Value))
-		return nil // 忽略消息, 正常情况下不会出现
+		impl.logger.Warn(ctx, "unexpected-topic", messageLogFields(msg)...)
+		return nil // 忽略消息, topic 不符合 TopicNamer 的命名规则
 	}
 
 	// 查找 handler
 	handler, ok := impl.handlers[msgType]
 	if !ok || handler == nil {
-		log.Println("not-found-handler", "msg-value", string(msg.Value))
+		impl.logger.Warn(ctx, "not-found-handler", append(messageLogFields(msg), "msg_type", msgType.String())...)
 		return nil // 忽略消息, 正常情况下不会出现
 	}
 
@@ -237,7 +448,8 @@ func (impl *consumerGroupHandler) handleMessage(msg *sarama.ConsumerMessage) err
 	msgValue := make([]byte, base64.StdEncoding.DecodedLen(len(msg.Value)))
 	n, err := base64.StdEncoding.Decode(msgValue, msg.Value)
 	if err != nil {
-		log.Println("base64-decode-msg-failed", "msg-value", string(msg.Value), "error", err.Error())
+		span.RecordError(err)
+		impl.logger.Error(ctx, "base64-decode-msg-failed", append(messageLogFields(msg), "error", err.Error())...)
 		return nil // 忽略消息, 正常情况下不会出现
 	}
 	msgValue = msgValue[:n]
@@ -253,10 +465,16 @@ func (impl *consumerGroupHandler) handleMessage(msg *sarama.ConsumerMessage) err
 			Offset:         msg.Offset,
 		},
 	}
-	err = handler.ServeMessage(context.Background(), bizMsg)
+	err = handler.ServeMessage(ctx, bizMsg)
 	if err != nil {
-		log.Println("handle-kafka-message-bus-message-failed", "msg-value", string(msg.Value), "error", err.Error())
+		span.RecordError(err)
+		impl.logger.Error(ctx, "handle-kafka-message-bus-message-failed", append(messageLogFields(msg), "msg_type", msgType.String(), "error", err.Error())...)
 		return err
 	}
 	return nil
 }
+
+// messageLogFields 返回定位消息需要的日志字段, 不包含消息内容, 防止敏感数据泄漏到日志.
+func messageLogFields(msg *sarama.ConsumerMessage) []interface{} {
+	return []interface{}{"msg_topic", msg.Topic, "msg_partition", msg.Partition, "msg_offset", msg.Offset, "msg_size", len(msg.Value)}
+}
@@ -0,0 +1,252 @@
+package kafka
+
+import (
+	"context"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"demo-to-start/trace"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestKafkaProducer_Close(t *testing.T) {
+	p := &kafkaProducer{producer: mocks.NewSyncProducer(t, nil), topicNamer: DefaultTopicNamer, logger: logger.Nop(), tracer: trace.Default()}
+	if err := p.lifecycle.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Close(context.Background()); err != nil {
+			t.Errorf("Close() #%d error = %v", i, err)
+		}
+	}
+	if err := p.SendMessage(context.Background(), 1, wrapperspb.String("x")); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("SendMessage() after Close error = %v, want ErrInvalidState", err)
+	}
+}
+
+// fakeConsumerGroup 的 Consume 阻塞到 ctx 结束或者 Close 被调用.
+type fakeConsumerGroup struct {
+	errors chan error
+	closed chan struct{}
+}
+
+func newFakeConsumerGroup() *fakeConsumerGroup {
+	return &fakeConsumerGroup{errors: make(chan error), closed: make(chan struct{})}
+}
+
+func (g *fakeConsumerGroup) Consume(ctx context.Context, _ []string, handler sarama.ConsumerGroupHandler) error {
+	_ = handler.Setup(fakeSession{})
+	defer handler.Cleanup(fakeSession{})
+	select {
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (g *fakeConsumerGroup) Errors() <-chan error { return g.errors }
+
+func (g *fakeConsumerGroup) Close() error {
+	close(g.closed)
+	close(g.errors)
+	return nil
+}
+
+// fakeSession 只实现 MemberID 和 Context.
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+}
+
+func (fakeSession) MemberID() string { return "member-1" }
+
+func (fakeSession) Context() context.Context { return context.Background() }
+
+// fakeClient 只实现 Close 和 RefreshMetadata.
+type fakeClient struct {
+	sarama.Client
+	closes    int
+	brokerErr common.Error
+	topics    common.Value[[]string]
+}
+
+func (c *fakeClient) RefreshMetadata(...string) error { return c.brokerErr.Load() }
+
+func (c *fakeClient) Topics() ([]string, error) { return c.topics.Load(), nil }
+
+func (c *fakeClient) Close() error {
+	c.closes++
+	return nil
+}
+
+func newTestConsumer() (*kafkaConsumer, *fakeClient) {
+	client := &fakeClient{}
+	c := &kafkaConsumer{client: client, consumerGroup: newFakeConsumerGroup(), topicNamer: DefaultTopicNamer, logger: logger.Nop(), tracer: trace.Default()}
+	c.lifecycle.Go(func() {
+		for range c.consumerGroup.Errors() {
+		}
+	})
+	return c, client
+}
+
+func TestKafkaConsumer_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	c, client := newTestConsumer()
+	handlers := map[MessageType]MessageHandler{1: nil}
+
+	if err := c.StartConsumeMessage(ctx, nil); err == nil {
+		t.Errorf("StartConsumeMessage() with empty handlers error = nil")
+	}
+	done := make(chan error)
+	go func() { done <- c.StartConsumeMessage(ctx, handlers) }()
+	if err := c.lifecycle.WaitFor(ctx, common.StateRunning); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartConsumeMessage(ctx, handlers); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("second StartConsumeMessage() error = %v, want ErrInvalidState", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Close(ctx); err != nil {
+			t.Errorf("Close() #%d error = %v", i, err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Errorf("StartConsumeMessage() error = %v after Close", err)
+	}
+	if client.closes != 1 || c.lifecycle.State() != common.StateStopped {
+		t.Errorf("client closes = %d, State() = %v", client.closes, c.lifecycle.State())
+	}
+
+	// 关闭之后不能再启动
+	if err := c.StartConsumeMessage(ctx, handlers); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("StartConsumeMessage() after Close error = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestKafkaConsumer_StartCanceled(t *testing.T) {
+	c, _ := newTestConsumer()
+	defer c.Close(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.StartConsumeMessage(ctx, map[MessageType]MessageHandler{1: nil}); !errors.Is(err, context.Canceled) {
+		t.Errorf("StartConsumeMessage() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestKafkaConsumer_Check(t *testing.T) {
+	ctx := context.Background()
+	c, client := newTestConsumer()
+	defer c.Close(ctx)
+	if err := c.Check(ctx); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("Check() before start error = %v", err)
+	}
+
+	go c.StartConsumeMessage(ctx, map[MessageType]MessageHandler{1: nil})
+	if err := c.lifecycle.WaitFor(ctx, common.StateRunning); err != nil {
+		t.Fatal(err)
+	}
+	// Setup 之后才加入了 consumer group
+	deadline := time.Now().Add(time.Second)
+	for c.Check(ctx) != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Check(ctx); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	client.brokerErr.Store(sarama.ErrOutOfBrokers)
+	if err := c.Check(ctx); !errors.Is(err, sarama.ErrOutOfBrokers) {
+		t.Errorf("Check() with brokers down error = %v", err)
+	}
+
+	client.brokerErr.Store(nil)
+	_ = c.Close(ctx)
+	if err := c.Check(ctx); !errors.Is(err, common.ErrInvalidState) {
+		t.Errorf("Check() after Close error = %v", err)
+	}
+}
+
+func TestKafkaConsumer_GroupHooks(t *testing.T) {
+	ctx := context.Background()
+	c, client := newTestConsumer()
+	var assigned common.Int32
+	c.onAssigned = func(context.Context) { assigned.Inc() }
+	var deletedBeforeClose common.Bool
+	c.deleteGroup = func() error {
+		deletedBeforeClose.Store(client.closes == 0)
+		return nil
+	}
+
+	done := make(chan error)
+	go func() { done <- c.StartConsumeMessage(ctx, map[MessageType]MessageHandler{1: nil}) }()
+	deadline := time.Now().Add(time.Second)
+	for assigned.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	// 加入 consumer group 之后调用 OnAssigned, 关闭 client 之前删除 group
+	if assigned.Load() != 1 || !deletedBeforeClose.Load() || client.closes != 1 {
+		t.Errorf("assigned = %d, deleted before client close = %v, client closes = %d", assigned.Load(), deletedBeforeClose.Load(), client.closes)
+	}
+
+	// 删除失败是关闭的错误
+	c, _ = newTestConsumer()
+	c.deleteGroup = func() error { return sarama.ErrGroupIDNotFound }
+	if err := c.Close(ctx); !errors.Is(err, sarama.ErrGroupIDNotFound) {
+		t.Errorf("Close() error = %v, want ErrGroupIDNotFound", err)
+	}
+}
+
+func TestKafkaConsumer_TopicsChanged(t *testing.T) {
+	handlers := map[MessageType]MessageHandler{1: nil}
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+	}{
+		{name: "added", before: []string{"demo.a"}, after: []string{"demo.a", "demo.b"}},
+		{name: "deleted", before: []string{"demo.a", "demo.b"}, after: []string{"demo.a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client := newTestConsumer()
+			defer c.Close(context.Background())
+			c.topicPattern = regexp.MustCompile(`^demo\.`)
+			c.topicRefreshInterval = time.Millisecond
+			client.topics.Store(tt.before)
+			topics, err := c.subscribedTopics(handlers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- c.consume(context.Background(), topics, handlers, &consumerGroupHandler{memberID: &c.memberID})
+			}()
+			// topics 没有变化时继续消费
+			select {
+			case err := <-done:
+				t.Fatalf("consume() returned %v before topics changed", err)
+			case <-time.After(20 * time.Millisecond):
+			}
+			client.topics.Store(tt.after)
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("consume() error = %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("consume() not restarted after topics changed")
+			}
+		})
+	}
+}
@@ -3,12 +3,13 @@ package kafka
 import (
 	"context"
 	"demo-to-start/common"
+	"demo-to-start/logger"
+	"demo-to-start/trace"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/Shopify/sarama"
 	"google.golang.org/protobuf/proto"
-	"log"
-	"strconv"
 	"time"
 )
 
@@ -22,53 +23,55 @@ type ProducerConfig struct {
 	User              string   // 可选; kafka 用户名
 	Password          string   // 可选; kafka 密码
 	DisableLogMessage bool     // 可选; 不打印消息日志, 默认为 false, 即表示打印
+
+	TopicNamer TopicNamer    // 可选; topic 命名规则, 默认 DefaultTopicNamer, 需要和 consumer 保持一致
+	Logger     logger.Logger // 可选; 日志, 默认 logger.Default()
+
+	PayloadLog PayloadLogConfig // 可选; 日志中消息内容的脱敏, 截断和采样
+	Tracer     *trace.Tracer    // 可选; 链路追踪, 默认 trace.Default(), kafka 版本不低于 0.11 时 trace context 写入消息头
 }
 
 type kafkaProducer struct {
 	logMessage bool
+	payload    *payloadFormatter
+	sampleRate uint64
+	sent       common.Uint64 // 发送成功的消息数, 用于日志采样
+	topicNamer TopicNamer
+	logger     logger.Logger
+	tracer     *trace.Tracer
+	headers    bool // kafka 版本是否支持消息头
 	producer   sarama.SyncProducer
-	closed     common.Bool
+	lifecycle  common.Lifecycle // 创建之后就是 Running
 }
 
+// Close 关闭 producer, 可以重复调用, 都返回第一次关闭的结果.
 func (impl *kafkaProducer) Close(ctx context.Context) error {
-	if !impl.closed.CompareAndSwap(false, true) {
-		return errors.New("the producer close method has been called")
-	}
-	if err := impl.producer.Close(); err != nil {
-		log.Println(ctx, "failed to close kafka producer", "error", err.Error())
-		return err
-	}
-	return nil
+	return impl.lifecycle.Stop(ctx, func(ctx context.Context) error {
+		if err := impl.producer.Close(); err != nil {
+			impl.logger.Error(ctx, "kafka-producer-close-failed", "error", err.Error())
+			return err
+		}
+		return nil
+	})
 }
 
 type sendMessageOptions struct {
 	partitionKey string
 }
 
-const kafkaTopicPrefix = "topic_"
-
-type MessageType int64
-
-func kafkaTopicFromMsgType(msgType MessageType) string {
-	return kafkaTopicPrefix + strconv.FormatInt(int64(msgType), 10)
-}
-func (m *MessageType) String() string {
-	if m == nil {
-		return ""
-	}
-	return strconv.FormatInt(int64(*m), 10)
-}
-
 // SendMessageOption 发送消息的可选配置.
 type SendMessageOption func(*sendMessageOptions)
 
 func (impl *kafkaProducer) SendMessage(ctx context.Context, msgType MessageType, msg proto.Message, opts ...SendMessageOption) error {
-	if impl.closed.Load() {
-		return errors.New("the producer has been closed")
+	if state := impl.lifecycle.State(); state != common.StateRunning {
+		return fmt.Errorf("send kafka message: %w", &common.StateError{Op: "send message", State: state})
 	}
 
 	// topic
-	topic := kafkaTopicFromMsgType(msgType)
+	topic := impl.topicNamer.Topic(msgType)
+
+	ctx, span := impl.tracer.Start(ctx, "kafka.send", "msg_type", msgType.String(), "msg_topic", topic)
+	defer span.End()
 
 	// key
 	var (
@@ -93,6 +96,7 @@ func (impl *kafkaProducer) SendMessage(ctx context.Context, msgType MessageType,
 	// value
 	msgData, err := Marshal(msg)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	base64MsgData := make([]byte, base64.StdEncoding.EncodedLen(len(msgData)))
@@ -105,22 +109,34 @@ func (impl *kafkaProducer) SendMessage(ctx context.Context, msgType MessageType,
 		Key:   key,
 		Value: value,
 	}
+	if impl.headers {
+		trace.Inject(ctx, producerHeaderCarrier{msg: kafkaMsg})
+	}
 	partition, offset, err := impl.producer.SendMessage(kafkaMsg)
 	if err != nil {
-		log.Println(ctx, "failed-to-send-message-to-kafka-message-bus", "msg_type", msgType.String(), "message", ToJsonString(msg), "error", err.Error())
+		span.RecordError(err)
+		impl.logger.Error(ctx, "failed-to-send-message-to-kafka-message-bus", "msg_type", msgType.String(), "message", impl.payload.Format(msg), "error", err.Error())
 		return err
 	}
+	span.SetAttributes("msg_partition", partition, "msg_offset", offset)
 
-	fields := []interface{}{"msg_type", msgType.String(), "message", ToJsonString(msg), "msg_key", keyString, "msg_topic", topic, "msg_partition", partition, "msg_offset", offset}
+	if !impl.logMessage || !impl.sampled() {
+		return nil
+	}
+	fields := []interface{}{"msg_type", msgType.String(), "message", impl.payload.Format(msg), "msg_key", keyString, "msg_topic", topic, "msg_partition", partition, "msg_offset", offset}
 	if !kafkaMsg.Timestamp.IsZero() {
 		fields = append(fields, "msg_timestamp", kafkaMsg.Timestamp.Format(timeLayout))
 	}
-	if impl.logMessage {
-		log.Println("success-to-send-message-to-kafka-message-bus", fields)
-	}
+	impl.logger.Info(ctx, "success-to-send-message-to-kafka-message-bus", fields...)
 	return nil
 }
 
+// sampled 返回这一条发送成功的消息是否需要打印日志, 每 sampleRate 条打印 1 条.
+func (impl *kafkaProducer) sampled() bool {
+	n := impl.sent.Inc()
+	return (n-1)%impl.sampleRate == 0
+}
+
 // NewKafkaProducer 创建一个新的 kafka Producer.
 //
 // NOTE: 不要忘记调用 Producer.Close, 否则会有资源泄漏.
@@ -147,6 +163,22 @@ func NewKafkaProducer(config ProducerConfig) (Producer, error) {
 		config.ClientID = "golang"
 	}
 
+	if config.TopicNamer == nil {
+		config.TopicNamer = DefaultTopicNamer
+	}
+
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+
+	if config.PayloadLog.SampleRate <= 0 {
+		config.PayloadLog.SampleRate = 1
+	}
+
+	if config.Tracer == nil {
+		config.Tracer = trace.Default()
+	}
+
 	kafkaConfig := sarama.NewConfig()
 	{
 		kafkaConfig.Version = kafkaVersion
@@ -178,8 +210,16 @@ func NewKafkaProducer(config ProducerConfig) (Producer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &kafkaProducer{
+	impl := &kafkaProducer{
 		logMessage: !config.DisableLogMessage,
+		payload:    newPayloadFormatter(config.PayloadLog),
+		sampleRate: uint64(config.PayloadLog.SampleRate),
+		topicNamer: config.TopicNamer,
+		logger:     config.Logger,
+		tracer:     config.Tracer,
+		headers:    kafkaVersion.IsAtLeast(sarama.V0_11_0_0),
 		producer:   producer,
-	}, nil
+	}
+	_ = impl.lifecycle.Start(nil) // 新创建的 Lifecycle 一定可以启动
+	return impl, nil
 }
@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	defaultMaxPayloadLogSize = 1024
+	redactedValue            = "[REDACTED]"
+)
+
+// PayloadLogConfig 是日志中打印消息内容相关的配置.
+type PayloadLogConfig struct {
+	RedactFields   []string                   // 可选; 需要脱敏的字段名, 匹配 proto 字段名或 json 名, 不区分大小写
+	RedactOption   protoreflect.ExtensionType // 可选; bool 类型的 google.protobuf.FieldOptions 扩展, 字段上该选项为 true 时脱敏
+	MaxPayloadSize int                        // 可选; 日志中消息内容的最大字节数, 超出部分被截断, 默认 1024, 小于 0 表示不限制
+	SampleRate     int                        // 可选; 发送成功的日志每 N 条打印 1 条, 默认 1 即全部打印
+}
+
+// payloadFormatter 把消息格式化为可以打印到日志的字符串: 脱敏 -> json -> 截断.
+type payloadFormatter struct {
+	redactFields map[string]struct{}
+	redactOption protoreflect.ExtensionType
+	maxSize      int
+}
+
+func newPayloadFormatter(config PayloadLogConfig) *payloadFormatter {
+	f := &payloadFormatter{
+		redactFields: make(map[string]struct{}, len(config.RedactFields)),
+		redactOption: config.RedactOption,
+		maxSize:      config.MaxPayloadSize,
+	}
+	for _, name := range config.RedactFields {
+		f.redactFields[strings.ToLower(name)] = struct{}{}
+	}
+	if f.maxSize == 0 {
+		f.maxSize = defaultMaxPayloadLogSize
+	}
+	return f
+}
+
+// Format 返回脱敏并截断之后的消息 json.
+func (f *payloadFormatter) Format(msg proto.Message) string {
+	if msg == nil {
+		return ""
+	}
+	if len(f.redactFields) > 0 || f.redactOption != nil {
+		msg = proto.Clone(msg)
+		f.redact(msg.ProtoReflect())
+	}
+	bs, err := protojson.MarshalOptions{AllowPartial: true}.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+	return truncatePayload(string(bs), f.maxSize)
+}
+
+func (f *payloadFormatter) redact(m protoreflect.Message) {
+	type field struct {
+		fd protoreflect.FieldDescriptor
+		v  protoreflect.Value
+	}
+	// 先收集再修改, Range 的过程中不能修改消息
+	var fields []field
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fields = append(fields, field{fd: fd, v: v})
+		return true
+	})
+
+	for _, field := range fields {
+		fd, v := field.fd, field.v
+		if f.shouldRedact(fd) {
+			if fd.Kind() == protoreflect.StringKind && fd.Cardinality() != protoreflect.Repeated {
+				m.Set(fd, protoreflect.ValueOfString(redactedValue))
+			} else {
+				m.Clear(fd)
+			}
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				continue
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				f.redact(mv.Message())
+				return true
+			})
+		case fd.IsList():
+			if fd.Message() == nil {
+				continue
+			}
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				f.redact(list.Get(i).Message())
+			}
+		case fd.Message() != nil:
+			f.redact(v.Message())
+		}
+	}
+}
+
+func (f *payloadFormatter) shouldRedact(fd protoreflect.FieldDescriptor) bool {
+	if _, ok := f.redactFields[strings.ToLower(string(fd.Name()))]; ok {
+		return true
+	}
+	if _, ok := f.redactFields[strings.ToLower(fd.JSONName())]; ok {
+		return true
+	}
+	if f.redactOption == nil {
+		return false
+	}
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || opts == nil || !proto.HasExtension(opts, f.redactOption) {
+		return false
+	}
+	redact, _ := proto.GetExtension(opts, f.redactOption).(bool)
+	return redact
+}
+
+// truncatePayload 把 s 截断到最多 max 个字节, 不会截断 utf8 字符, max 小于 0 表示不限制.
+func truncatePayload(s string, max int) string {
+	if max < 0 || len(s) <= max {
+		return s
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "...(truncated " + strconv.Itoa(len(s)-n) + " bytes)"
+}
@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestPayloadFormatter_RedactFields(t *testing.T) {
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("User"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("phone"), JsonName: proto.String("phone"), Number: proto.Int32(1)},
+		},
+	}
+	f := newPayloadFormatter(PayloadLogConfig{RedactFields: []string{"NAME", "jsonName"}})
+	got := f.Format(msg)
+
+	if strings.Contains(got, "User") || strings.Contains(got, "phone") {
+		t.Errorf("Format() = %s, sensitive fields not redacted", got)
+	}
+	if !strings.Contains(got, redactedValue) || !strings.Contains(got, `"number":1`) {
+		t.Errorf("Format() = %s, want redacted name and kept number", got)
+	}
+	if msg.GetName() != "User" {
+		t.Errorf("Format() modified the original message")
+	}
+}
+
+func TestPayloadFormatter_RedactOption(t *testing.T) {
+	redactOption, user := buildRedactTestTypes(t)
+
+	msg := dynamicpb.NewMessage(user)
+	msg.Set(user.Fields().ByName("name"), protoreflect.ValueOfString("alice"))
+	msg.Set(user.Fields().ByName("phone"), protoreflect.ValueOfString("13800000000"))
+
+	got := newPayloadFormatter(PayloadLogConfig{RedactOption: redactOption}).Format(msg)
+	if !strings.Contains(got, "alice") || strings.Contains(got, "13800000000") {
+		t.Errorf("Format() = %s, want only phone redacted", got)
+	}
+}
+
+func TestTruncatePayload(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{name: "short", s: "abc", max: 3, want: "abc"},
+		{name: "unlimited", s: "abcdef", max: -1, want: "abcdef"},
+		{name: "ascii", s: "abcdef", max: 4, want: "abcd...(truncated 2 bytes)"},
+		{name: "utf8 boundary", s: "中文", max: 4, want: "中...(truncated 3 bytes)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncatePayload(tt.s, tt.max); got != tt.want {
+				t.Errorf("truncatePayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKafkaProducer_sampled(t *testing.T) {
+	p := &kafkaProducer{sampleRate: 3}
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, p.sampled())
+	}
+	want := []bool{true, false, false, true, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sampled() = %v, want %v", got, want)
+		}
+	}
+}
+
+// buildRedactTestTypes 构造 `extend google.protobuf.FieldOptions { bool redact = 50000; }`
+// 以及 `message User { string name = 1; string phone = 2 [(redact) = true]; }`.
+func buildRedactTestTypes(t *testing.T) (protoreflect.ExtensionType, protoreflect.MessageDescriptor) {
+	t.Helper()
+
+	optionFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("redact.proto"),
+		Package:    proto.String("test"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("redact"),
+			Number:   proto.Int32(50000),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+			Extendee: proto.String(".google.protobuf.FieldOptions"),
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactOption := dynamicpb.NewExtensionType(optionFile.Extensions().Get(0))
+
+	phoneOptions := &descriptorpb.FieldOptions{}
+	proto.SetExtension(phoneOptions, redactOption, true)
+	userFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("user.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("name"), JsonName: proto.String("name"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("phone"), JsonName: proto.String("phone"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Options: phoneOptions},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redactOption, userFile.Messages().Get(0)
+}
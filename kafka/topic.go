@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const kafkaTopicPrefix = "topic_"
+
+// MessageType 消息类型, 通过 TopicNamer 映射到 kafka topic.
+type MessageType int64
+
+func (m *MessageType) String() string {
+	if m == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*m), 10)
+}
+
+// TopicNamer 是 MessageType 和 kafka topic 之间的双向映射.
+//
+// ⚠️注意: producer 和 consumer 必须使用相同的 TopicNamer, 否则消息无法被消费到.
+type TopicNamer interface {
+	// Topic 返回 MessageType 对应的 topic.
+	Topic(MessageType) string
+
+	// MessageType 解析 topic 对应的 MessageType, topic 不符合命名规则时返回 false.
+	MessageType(topic string) (MessageType, bool)
+}
+
+// DefaultTopicNamer 默认的 topic 命名规则, 即 "topic_" + MessageType.
+var DefaultTopicNamer TopicNamer = PrefixTopicNamer(kafkaTopicPrefix)
+
+// PrefixTopicNamer 返回 prefix + MessageType 的 topic 命名规则.
+func PrefixTopicNamer(prefix string) TopicNamer {
+	return prefixTopicNamer(prefix)
+}
+
+type prefixTopicNamer string
+
+func (prefix prefixTopicNamer) Topic(msgType MessageType) string {
+	return string(prefix) + strconv.FormatInt(int64(msgType), 10)
+}
+
+func (prefix prefixTopicNamer) MessageType(topic string) (MessageType, bool) {
+	if !strings.HasPrefix(topic, string(prefix)) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(topic[len(prefix):], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return MessageType(n), true
+}
+
+const topicNamePlaceholder = "{name}"
+
+var topicPlaceholderRegexp = regexp.MustCompile(`\{[a-zA-Z0-9_]+\}`)
+
+// NewTemplateTopicNamer 创建一个基于模板的 TopicNamer, 例如 "{env}.{service}.{name}".
+//
+// vars 提供模板中除 {name} 以外的占位符的值, 例如 {"env": "prod", "service": "user"}.
+// names 是 MessageType 对应的 {name}, 没有配置的 MessageType 使用数字本身.
+func NewTemplateTopicNamer(template string, vars map[string]string, names map[MessageType]string) (TopicNamer, error) {
+	if strings.Count(template, topicNamePlaceholder) != 1 {
+		return nil, errors.New("template must contain exactly one {name}")
+	}
+
+	var (
+		prefix, suffix string
+		err            error
+	)
+	idx := strings.Index(template, topicNamePlaceholder)
+	if prefix, err = expandTopicTemplate(template[:idx], vars); err != nil {
+		return nil, err
+	}
+	if suffix, err = expandTopicTemplate(template[idx+len(topicNamePlaceholder):], vars); err != nil {
+		return nil, err
+	}
+
+	namer := &templateTopicNamer{
+		prefix: prefix,
+		suffix: suffix,
+		names:  make(map[MessageType]string, len(names)),
+		types:  make(map[string]MessageType, len(names)),
+	}
+	for msgType, name := range names {
+		if name == "" {
+			return nil, errors.New("empty name for message type " + msgType.String())
+		}
+		if _, ok := namer.types[name]; ok {
+			return nil, errors.New("duplicated name " + name)
+		}
+		namer.names[msgType] = name
+		namer.types[name] = msgType
+	}
+	return namer, nil
+}
+
+func expandTopicTemplate(s string, vars map[string]string) (string, error) {
+	var err error
+	s = topicPlaceholderRegexp.ReplaceAllStringFunc(s, func(placeholder string) string {
+		value, ok := vars[placeholder[1:len(placeholder)-1]]
+		if !ok && err == nil {
+			err = errors.New("missing value for placeholder " + placeholder)
+		}
+		return value
+	})
+	return s, err
+}
+
+type templateTopicNamer struct {
+	prefix string
+	suffix string
+	names  map[MessageType]string
+	types  map[string]MessageType
+}
+
+func (impl *templateTopicNamer) Topic(msgType MessageType) string {
+	name, ok := impl.names[msgType]
+	if !ok {
+		name = strconv.FormatInt(int64(msgType), 10)
+	}
+	return impl.prefix + name + impl.suffix
+}
+
+func (impl *templateTopicNamer) MessageType(topic string) (MessageType, bool) {
+	if len(topic) <= len(impl.prefix)+len(impl.suffix) ||
+		!strings.HasPrefix(topic, impl.prefix) || !strings.HasSuffix(topic, impl.suffix) {
+		return 0, false
+	}
+	name := topic[len(impl.prefix) : len(topic)-len(impl.suffix)]
+	if msgType, ok := impl.types[name]; ok {
+		return msgType, true
+	}
+	n, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if _, ok := impl.names[MessageType(n)]; ok {
+		return 0, false // 配置了 name 的 MessageType 只能通过 name 解析
+	}
+	return MessageType(n), true
+}
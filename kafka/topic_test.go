@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"testing"
+)
+
+func TestPrefixTopicNamer(t *testing.T) {
+	namer := DefaultTopicNamer
+	if got := namer.Topic(12); got != "topic_12" {
+		t.Errorf("Topic() = %v, want %v", got, "topic_12")
+	}
+	tests := []struct {
+		name   string
+		topic  string
+		want   MessageType
+		wantOK bool
+	}{
+		{name: "normal", topic: "topic_12", want: 12, wantOK: true},
+		{name: "large", topic: "topic_8589934592", want: 8589934592, wantOK: true},
+		{name: "wrong prefix", topic: "prod.topic_12", wantOK: false},
+		{name: "not number", topic: "topic_user", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := namer.MessageType(tt.topic)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("MessageType() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTemplateTopicNamer(t *testing.T) {
+	namer, err := NewTemplateTopicNamer("{env}.{service}.{name}",
+		map[string]string{"env": "prod", "service": "user"},
+		map[MessageType]string{1: "user-created", 2: "user-deleted"})
+	if err != nil {
+		t.Fatalf("NewTemplateTopicNamer() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		msgType MessageType
+		topic   string
+	}{
+		{name: "named", msgType: 1, topic: "prod.user.user-created"},
+		{name: "unnamed", msgType: 3, topic: "prod.user.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := namer.Topic(tt.msgType); got != tt.topic {
+				t.Errorf("Topic() = %v, want %v", got, tt.topic)
+			}
+			if got, ok := namer.MessageType(tt.topic); !ok || got != tt.msgType {
+				t.Errorf("MessageType() = %v, %v, want %v, true", got, ok, tt.msgType)
+			}
+		})
+	}
+
+	for _, topic := range []string{"test.user.user-created", "prod.user.", "prod.user.1", "topic_1"} {
+		if got, ok := namer.MessageType(topic); ok {
+			t.Errorf("MessageType(%q) = %v, want not ok", topic, got)
+		}
+	}
+}
+
+func TestNewTemplateTopicNamer_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		names    map[MessageType]string
+	}{
+		{name: "missing name", template: "{env}.user"},
+		{name: "duplicated placeholder", template: "{name}.{name}"},
+		{name: "missing var", template: "{env}.{name}"},
+		{name: "duplicated name", template: "{name}", names: map[MessageType]string{1: "a", 2: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTemplateTopicNamer(tt.template, tt.vars, tt.names); err == nil {
+				t.Errorf("NewTemplateTopicNamer() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const badKey = "!BADKEY"
+
+// NewJSONLogger 创建一个每行输出一个 JSON 对象的 Logger, 低于 level 的日志会被丢弃.
+//
+// 输出格式: {"time":"...","level":"info","msg":"...","trace_id":"...",<ctx 字段>,<keyvals>}
+func NewJSONLogger(w io.Writer, level Level) Logger {
+	return &jsonLogger{w: w, level: level}
+}
+
+type jsonLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+func (impl *jsonLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, LevelDebug, msg, keyvals)
+}
+
+func (impl *jsonLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, LevelInfo, msg, keyvals)
+}
+
+func (impl *jsonLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, LevelWarn, msg, keyvals)
+}
+
+func (impl *jsonLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, LevelError, msg, keyvals)
+}
+
+func (impl *jsonLogger) log(ctx context.Context, level Level, msg string, keyvals []interface{}) {
+	if level < impl.level {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", time.Now().Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	writeJSONField(&buf, "level", level.String())
+	buf.WriteByte(',')
+	writeJSONField(&buf, "msg", msg)
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		buf.WriteByte(',')
+		writeJSONField(&buf, "trace_id", traceID)
+	}
+	writeJSONKeyvals(&buf, FieldsFromContext(ctx))
+	writeJSONKeyvals(&buf, keyvals)
+	buf.WriteString("}\n")
+
+	impl.mu.Lock()
+	_, _ = impl.w.Write(buf.Bytes())
+	impl.mu.Unlock()
+}
+
+func writeJSONKeyvals(buf *bytes.Buffer, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok || i+1 == len(keyvals) {
+			// key 不是 string 或者缺少 value, 整体作为 value 输出
+			buf.WriteByte(',')
+			writeJSONField(buf, badKey, keyvals[i])
+			i--
+			continue
+		}
+		buf.WriteByte(',')
+		writeJSONField(buf, key, keyvals[i+1])
+	}
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	buf.Write(k)
+	buf.WriteByte(':')
+
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	case json.Marshaler:
+	case fmt.Stringer:
+		value = v.String()
+	}
+	bs, err := json.Marshal(value)
+	if err != nil {
+		bs, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(bs)
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Level 日志级别.
+type Level int8
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseLevel 解析 debug/info/warn/error, 不区分大小写.
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error":
+		return LevelError, true
+	default:
+		return LevelInfo, false
+	}
+}
+
+// Logger 是结构化的分级日志接口.
+//
+// keyvals 是交替出现的 key/value, 例如 Info(ctx, "kafka-consumer-closed", "group", group).
+// key 必须是 string, 实现需要输出 ctx 中携带的 trace id 和字段.
+type Logger interface {
+	Debug(ctx context.Context, msg string, keyvals ...interface{})
+	Info(ctx context.Context, msg string, keyvals ...interface{})
+	Warn(ctx context.Context, msg string, keyvals ...interface{})
+	Error(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+var defaultLogger atomic.Value // Logger
+
+func init() {
+	defaultLogger.Store(loggerHolder{NewJSONLogger(os.Stderr, LevelInfo)})
+}
+
+type loggerHolder struct{ Logger }
+
+// Default 返回默认的 Logger, 各个包的配置没有指定 Logger 时使用.
+func Default() Logger {
+	return defaultLogger.Load().(loggerHolder).Logger
+}
+
+// SetDefault 设置默认的 Logger, 需要在创建各个组件之前调用.
+func SetDefault(l Logger) {
+	if l == nil {
+		return
+	}
+	defaultLogger.Store(loggerHolder{l})
+}
+
+// Nop 返回一个丢弃所有日志的 Logger.
+func Nop() Logger { return nopLogger{} }
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(context.Context, string, ...interface{}) {}
+func (nopLogger) Info(context.Context, string, ...interface{})  {}
+func (nopLogger) Warn(context.Context, string, ...interface{})  {}
+func (nopLogger) Error(context.Context, string, ...interface{}) {}
+
+type traceIDKey struct{}
+
+type fieldsKey struct{}
+
+// WithTraceID 返回携带 trace id 的 context, Logger 会输出为 trace_id 字段.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext 返回 ctx 中的 trace id, 没有则返回空字符串.
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// WithFields 返回携带日志字段的 context, 之后使用该 ctx 的日志都会输出这些字段.
+func WithFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	if len(keyvals) == 0 {
+		return ctx
+	}
+	fields := FieldsFromContext(ctx)
+	merged := make([]interface{}, 0, len(fields)+len(keyvals))
+	merged = append(merged, fields...)
+	merged = append(merged, keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext 返回 ctx 中通过 WithFields 设置的日志字段.
+func FieldsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
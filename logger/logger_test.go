@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, LevelInfo)
+
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx = WithFields(ctx, "request_id", "r-1")
+	l.Debug(ctx, "dropped")
+	l.Error(ctx, "kafka-consumer-close-failed", "error", errors.New("boom"), "topics", []string{"a", "b"}, "dangling")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), buf.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("invalid json %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"level":      "error",
+		"msg":        "kafka-consumer-close-failed",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"request_id": "r-1",
+		"error":      "boom",
+		badKey:       "dangling",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %s = %v, want %v", k, got[k], v)
+		}
+	}
+	if topics, _ := got["topics"].([]interface{}); len(topics) != 2 {
+		t.Errorf("field topics = %v, want [a b]", got["topics"])
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	l.Info(WithTraceID(context.Background(), "abc"), "hello", "k", 1)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if got["msg"] != "hello" || got["trace_id"] != "abc" || got["k"] != float64(1) {
+		t.Errorf("unexpected record %v", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   Level
+		wantOK bool
+	}{
+		{s: "DEBUG", want: LevelDebug, wantOK: true},
+		{s: "warning", want: LevelWarn, wantOK: true},
+		{s: "error", want: LevelError, wantOK: true},
+		{s: "verbose", want: LevelInfo, wantOK: false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseLevel(tt.s); got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
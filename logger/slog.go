@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger 把 *slog.Logger 适配为 Logger, ctx 中的 trace id 输出为 trace_id 属性.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (impl *slogLogger) Debug(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, slog.LevelDebug, msg, keyvals)
+}
+
+func (impl *slogLogger) Info(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, slog.LevelInfo, msg, keyvals)
+}
+
+func (impl *slogLogger) Warn(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, slog.LevelWarn, msg, keyvals)
+}
+
+func (impl *slogLogger) Error(ctx context.Context, msg string, keyvals ...interface{}) {
+	impl.log(ctx, slog.LevelError, msg, keyvals)
+}
+
+func (impl *slogLogger) log(ctx context.Context, level slog.Level, msg string, keyvals []interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !impl.l.Enabled(ctx, level) {
+		return
+	}
+	fields := FieldsFromContext(ctx)
+	args := make([]interface{}, 0, 2+len(fields)+len(keyvals))
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		args = append(args, "trace_id", traceID)
+	}
+	args = append(args, fields...)
+	args = append(args, keyvals...)
+	impl.l.Log(ctx, level, msg, args...)
+}
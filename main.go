@@ -1,35 +1,263 @@
 package main
 
 import (
+	"context"
+	"demo-to-start/app"
+	"demo-to-start/cachebus"
+	"demo-to-start/common"
 	"demo-to-start/handlers"
+	"demo-to-start/health"
+	"demo-to-start/kafka"
 	"demo-to-start/mysql"
+	"demo-to-start/trace"
+	"demo-to-start/usercache"
+	"expvar"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
+const defaultRequestTimeout = 5 * time.Second
+
 func main() {
 	log.SetFlags(log.Lshortfile)
-	// 数据库初始化
-	err := mysql.RegisterDB()
-	if err != nil {
-		log.Println(err)
+	// 数据库迁移子命令: migrate up|down|status|create
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 		return
 	}
+	// 创建或者启动失败时以非 0 状态码退出
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run 创建所有组件并注册到 app.App, 按注册顺序启动, 收到 SIGINT/SIGTERM 时按相反的顺序停止:
+// 就绪检查 → http server → cache bus → 用户缓存 → kafka producer → 数据库.
+func run() error {
+	// 链路追踪, TRACE_EXPORTER=stdout 时把 span 打印到标准输出
+	if os.Getenv("TRACE_EXPORTER") == "stdout" {
+		trace.SetDefault(trace.NewTracer(trace.NewStdoutExporter(os.Stdout)))
+	}
+	// SHUTDOWN_TIMEOUT 是停止所有组件的总超时时间, 默认 10s
+	shutdownTimeout, err := envDuration("SHUTDOWN_TIMEOUT")
+	if err != nil {
+		return err
+	}
+	application := app.New(app.Config{ShutdownTimeout: shutdownTimeout})
+	// 就绪检查, 停止时先变为未就绪, 等待 SHUTDOWN_DELAY (默认 0) 让负载均衡摘除流量之后再停止 http server
+	shutdownDelay, err := envDuration("SHUTDOWN_DELAY")
+	if err != nil {
+		return err
+	}
+	readiness := health.NewReadiness(health.Config{ShutdownDelay: shutdownDelay})
+
+	// 数据库初始化, MYSQL_CONFIG_FILE 指定 json 配置文件, MYSQL_ 开头的环境变量覆盖文件中的配置
+	dbConfig, err := mysql.LoadConfig(os.Getenv("MYSQL_CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
+	db, err := mysql.Open(context.Background(), dbConfig)
+	if err != nil {
+		return err
+	}
+	err = application.Register("mysql", app.Hook{
+		// MYSQL_MIGRATE_ON_START=true 时启动前执行数据库迁移, 多个实例同时启动时只有一个会执行
+		OnStart: func(ctx context.Context) error {
+			if !dbConfig.MigrateOnStart {
+				return nil
+			}
+			migrator, err := mysql.NewMigrator(mysql.MigratorConfig{DB: db})
+			if err != nil {
+				return err
+			}
+			_, err = migrator.Up(ctx)
+			return err
+		},
+		OnStop: func(context.Context) error { return db.Close() },
+	})
+	if err != nil {
+		return err
+	}
+	if err := readiness.Register("mysql", health.Ping(db)); err != nil {
+		return err
+	}
+	users, err := mysql.NewUserRepository(mysql.UserRepositoryConfig{DB: db, QueryTimeout: dbConfig.QueryTimeout})
+	if err != nil {
+		return err
+	}
+	// 缓存失效广播, KAFKA_BROKERS 不为空时通过 kafka 在多个实例之间同步用户缓存的失效
+	var (
+		brokers     = splitList(os.Getenv("KAFKA_BROKERS"))
+		instanceID  = cachebus.NewInstanceID()
+		broadcaster usercache.Broadcaster
+	)
+	if len(brokers) > 0 {
+		producer, err := kafka.NewKafkaProducer(kafka.ProducerConfig{Brokers: brokers})
+		if err != nil {
+			return err
+		}
+		if err := application.Register("kafka-producer", app.Hook{OnStop: producer.Close}); err != nil {
+			return err
+		}
+		b, err := cachebus.NewBroadcaster(cachebus.BroadcasterConfig{Producer: producer, InstanceID: instanceID})
+		if err != nil {
+			return err
+		}
+		broadcaster = b
+	}
+	// 用户缓存, 命中率等统计数据通过 /debug/vars 的 user_cache 查看;
+	// USER_CACHE_SNAPSHOT_FILE 不为空时启动时从文件恢复缓存, 停止服务时保存
+	snapshotFile := os.Getenv("USER_CACHE_SNAPSHOT_FILE")
+	cachedUsers, err := usercache.NewUserRepository(usercache.UserRepositoryConfig{
+		Users:        users,
+		Broadcaster:  broadcaster,
+		SnapshotFile: snapshotFile,
+	})
+	if err != nil {
+		return err
+	}
+	// 启动时恢复快照, 恢复完成之前未就绪; http server 和 cache bus 先停止, 保存快照时缓存不会再变化
+	err = application.Register("user-cache", app.Hook{
+		OnStart: func(ctx context.Context) error {
+			cachedUsers.WarmUp(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error { return cachedUsers.Close() },
+	})
+	if err != nil {
+		return err
+	}
+	if err := readiness.Register("user-cache", cachedUsers); err != nil {
+		return err
+	}
+	expvar.Publish("user_cache", expvar.Func(func() interface{} { return cachedUsers.Stats() }))
+	// 所有缓存的统计数据通过 /debug/caches 查看
+	caches := common.NewCacheRegistry()
+	if err := caches.Register(usercache.CacheName, cachedUsers.CacheReporter()); err != nil {
+		return err
+	}
+	cacheHandler, err := handlers.NewCacheHandler(handlers.CacheHandlerConfig{Registry: caches})
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		// 每个实例使用独立的 consumer group, 才能收到全部的失效消息
+		group := os.Getenv("CACHE_BUS_GROUP")
+		if group == "" {
+			group = "demo-to-start-cache"
+		}
+		// 每次加入 group 之后清空缓存, 停止时删除 group, 否则每次启动都会留下一个 group
+		var listener *cachebus.Listener
+		consumer, err := kafka.NewKafkaConsumer(kafka.ConsumerConfig{
+			Brokers:            brokers,
+			Group:              cachebus.InstanceGroup(group, instanceID),
+			OnAssigned:         func(ctx context.Context) { listener.OnAssigned(ctx) },
+			DeleteGroupOnClose: true,
+		})
+		if err != nil {
+			return err
+		}
+		listener, err = cachebus.NewListener(cachebus.ListenerConfig{
+			Consumer:   consumer,
+			InstanceID: instanceID,
+			Caches:     map[string]cachebus.Invalidator{usercache.CacheName: cachedUsers},
+			// 从快照恢复的缓存不清空, 否则恢复就没有意义了
+			KeepOnStart: snapshotFile != "",
+		})
+		if err != nil {
+			_ = consumer.Close(context.Background())
+			return err
+		}
+		if err := application.Register("cache-bus", app.NewService(listener.Start, listener.Close)); err != nil {
+			return err
+		}
+		// broker 不可用或者没有加入 consumer group 时收不到失效消息, 缓存可能是旧的
+		if err := readiness.Register("cache-bus", listener); err != nil {
+			return err
+		}
+	}
+	userHandler, err := handlers.NewUserHandler(handlers.UserHandlerConfig{Users: cachedUsers})
+	if err != nil {
+		return err
+	}
+	// REQUEST_TIMEOUT 是用户接口的超时时间, 默认 5s
+	requestTimeout, err := envDuration("REQUEST_TIMEOUT")
+	if err != nil {
+		return err
+	}
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	healthHandler, err := handlers.NewHealthHandler(handlers.HealthHandlerConfig{Readiness: readiness})
+	if err != nil {
+		return err
+	}
 	// 服务初始化
-	Server()
+	if err := application.Register("http-server", app.NewHTTPServer(Server(requestTimeout, userHandler, cacheHandler, healthHandler))); err != nil {
+		return err
+	}
+	// 最后注册, 停止时最先变为未就绪
+	if err := application.Register("readiness", readiness); err != nil {
+		return err
+	}
+	return application.Run(context.Background())
 }
 
 // Server 网络服务
-func Server() {
-	// 1.注册一个处理器函数,这里没有限制Get/Post等http方法
-	http.HandleFunc("/get_user", handlers.QueryUser)
+func Server(requestTimeout time.Duration, userHandler *handlers.UserHandler, cacheHandler *handlers.CacheHandler, healthHandler *handlers.HealthHandler) *http.Server {
+	// 1.注册路由, 所有请求先经过请求 id, trace context, 访问日志和 panic 恢复; 方法不匹配时返回 405
+	router := handlers.NewRouter()
+	router.Use(handlers.RequestID(), handlers.Trace(), handlers.AccessLog(nil), handlers.Recover(nil))
+
+	// 访问数据库的接口超过 requestTimeout 时返回 503, 查询随 r.Context() 取消
+	api := router.Group("", handlers.Timeout(requestTimeout, nil))
+	api.Get("/get_user", userHandler.QueryUser)
+	api.Post("/get_user", userHandler.QueryUser)
+	userHandler.RegisterRoutes(api)
+
+	// 内部排查接口, 不要暴露到公网
+	debug := router.Group("/debug")
+	debug.Handle(http.MethodGet, "/vars", expvar.Handler())
+	debug.Get("/caches", cacheHandler.Caches)
+	debug.Get("/caches/metrics", cacheHandler.Metrics)
+
+	router.Get("/healthz", healthHandler.Healthz)
+	router.Get("/readyz", healthHandler.Readyz)
+
+	// 2.设置监听的TCP地址, 由 app.HTTPServer 启动和停止
+	// Addr:TCP地址(IP+Port)
+	// Handler:使用 handlers.Router, 不再使用 DefaultServeMux。
+	return &http.Server{Addr: ":9000", Handler: router}
+}
 
-	// 2.设置监听的TCP地址并启动服务
-	// 参数1:TCP地址(IP+Port)
-	// 参数2:handler handler参数一般会设为nil，此时会使用DefaultServeMux。
-	err := http.ListenAndServe(":9000", nil)
+// envDuration 解析环境变量中的时间, 例如 10s, 为空时返回 0.
+func envDuration(name string) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
 	if err != nil {
-		log.Println("http.ListenAndServe()函数执行错误,错误为:", err.Error())
-		return
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
+
+// splitList 按逗号拆分环境变量, 去掉空白和空项.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
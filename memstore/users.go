@@ -0,0 +1,93 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"demo-to-start/model"
+	"sort"
+	"sync"
+)
+
+// NewUserRepository 创建一个基于内存的 model.UserRepository, 用于测试和本地开发.
+func NewUserRepository(users ...model.User) model.UserRepository {
+	repo := &userRepository{users: make(map[int]model.User, len(users))}
+	for _, user := range users {
+		repo.users[user.ID] = user
+		if user.ID > repo.lastID {
+			repo.lastID = user.ID
+		}
+	}
+	return repo
+}
+
+type userRepository struct {
+	mu     sync.RWMutex
+	users  map[int]model.User
+	lastID int
+}
+
+func (impl *userRepository) Get(_ context.Context, id int) (model.User, error) {
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+	user, ok := impl.users[id]
+	if !ok {
+		return model.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (impl *userRepository) List(_ context.Context, offset, limit int) ([]model.User, error) {
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+
+	ids := make([]int, 0, len(impl.users))
+	for id := range impl.users {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(ids) || limit <= 0 {
+		return []model.User{}, nil
+	}
+	ids = ids[offset:]
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	users := make([]model.User, 0, len(ids))
+	for _, id := range ids {
+		users = append(users, impl.users[id])
+	}
+	return users, nil
+}
+
+func (impl *userRepository) Create(_ context.Context, user model.User) (model.User, error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	impl.lastID++
+	user.ID = impl.lastID
+	impl.users[user.ID] = user
+	return user, nil
+}
+
+func (impl *userRepository) Update(_ context.Context, user model.User) error {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	if _, ok := impl.users[user.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	impl.users[user.ID] = user
+	return nil
+}
+
+func (impl *userRepository) Delete(_ context.Context, id int) error {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	if _, ok := impl.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(impl.users, id)
+	return nil
+}
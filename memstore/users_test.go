@@ -0,0 +1,49 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"demo-to-start/model"
+	"errors"
+	"testing"
+)
+
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(model.User{ID: 3, Name: "carol", Age: 30})
+
+	created, err := repo.Create(ctx, model.User{ID: 100, Name: "dave", Age: 40})
+	if err != nil || created.ID != 4 {
+		t.Fatalf("Create() = %+v, %v, want id 4", created, err)
+	}
+	if err := repo.Update(ctx, model.User{ID: 4, Name: "dave", Age: 41}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, err := repo.Get(ctx, 4); err != nil || got.Age != 41 {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+
+	users, err := repo.List(ctx, 0, 10)
+	if err != nil || len(users) != 2 || users[0].ID != 3 || users[1].ID != 4 {
+		t.Errorf("List() = %+v, %v", users, err)
+	}
+	if users, _ := repo.List(ctx, 1, 1); len(users) != 1 || users[0].ID != 4 {
+		t.Errorf("List(1, 1) = %+v", users)
+	}
+	if users, _ := repo.List(ctx, 5, 1); len(users) != 0 {
+		t.Errorf("List(5, 1) = %+v", users)
+	}
+
+	if err := repo.Delete(ctx, 3); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	for name, err := range map[string]error{
+		"get":    func() error { _, err := repo.Get(ctx, 3); return err }(),
+		"update": repo.Update(ctx, model.User{ID: 3}),
+		"delete": repo.Delete(ctx, 3),
+	} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s missing user error = %v, want sql.ErrNoRows", name, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"demo-to-start/mysql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const migrateUsage = `usage: demo-to-start migrate <command> [flags]
+
+commands:
+  up                      执行所有未执行的迁移
+  down [-steps N]         回滚最近执行的 N 个迁移, 默认 1
+  status                  查看迁移状态
+  create [-dir D] <name>  在 D 下创建下一个版本的空迁移文件, 默认 ` + mysql.MigrationsDir
+
+// runMigrate 执行 migrate 子命令, 数据库配置和启动服务时相同.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "up", "down", "status", "create":
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+	fset := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	steps := fset.Int("steps", 1, "回滚的迁移个数")
+	dir := fset.String("dir", mysql.MigrationsDir, "迁移文件目录")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	if command == "create" {
+		if fset.NArg() != 1 {
+			return errors.New(migrateUsage)
+		}
+		up, down, err := mysql.CreateMigration(*dir, fset.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+	}
+
+	ctx := context.Background()
+	dbConfig, err := mysql.LoadConfig(os.Getenv("MYSQL_CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
+	db, err := mysql.Open(ctx, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := mysql.NewMigrator(mysql.MigratorConfig{DB: db})
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations("applied", done)
+		return err
+	case "down":
+		done, err := migrator.Down(ctx, *steps)
+		printMigrations("reverted", done)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Missing:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05") + " (file missing)"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	}
+	return nil
+}
+
+func printMigrations(action string, migrations []mysql.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("no migration", action)
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+}
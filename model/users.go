@@ -1,7 +1,29 @@
 package model
 
+import "context"
+
 type User struct {
 	ID   int
 	Name string
 	Age  int
 }
+
+// UserRepository 是用户的存储接口.
+//
+// ⚠️注意: 用户不存在时 Get, Update, Delete 返回 sql.ErrNoRows, 调用方使用 errors.Is 判断.
+type UserRepository interface {
+	// Get 返回 id 对应的用户.
+	Get(ctx context.Context, id int) (User, error)
+
+	// List 按 id 升序返回跳过 offset 个之后的最多 limit 个用户.
+	List(ctx context.Context, offset, limit int) ([]User, error)
+
+	// Create 创建用户, 忽略 user.ID, 返回带有新 ID 的用户.
+	Create(ctx context.Context, user User) (User, error)
+
+	// Update 按 user.ID 更新用户.
+	Update(ctx context.Context, user User) error
+
+	// Delete 删除 id 对应的用户.
+	Delete(ctx context.Context, id int) error
+}
@@ -0,0 +1,216 @@
+package mysql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"demo-to-start/logger"
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// Config 是 mysql 连接相关配置, 可以通过 LoadConfig 从 json 文件和环境变量中加载.
+type Config struct {
+	Host      string `json:"host"`       // 必须; 地址
+	Port      int    `json:"port"`       // 可选; 端口, 默认 3306
+	User      string `json:"user"`       // 必须; 用户名
+	Password  string `json:"password"`   // 可选; 密码
+	Database  string `json:"database"`   // 必须; 数据库
+	TLS       string `json:"tls"`        // 可选; true/false/skip-verify/preferred 或者通过 RegisterTLSConfig 注册的名称
+	Charset   string `json:"charset"`    // 可选; 字符集, 默认 utf8mb4
+	ParseTime bool   `json:"parse_time"` // 可选; DATE/DATETIME 解析为 time.Time
+
+	Timeout      time.Duration `json:"-"` // 可选; 建立连接超时, 默认 5s, json 中为 "5s" 格式, 下同
+	ReadTimeout  time.Duration `json:"-"` // 可选; 读超时
+	WriteTimeout time.Duration `json:"-"` // 可选; 写超时
+	QueryTimeout time.Duration `json:"-"` // 可选; 单次查询超时, 传给 UserRepositoryConfig.QueryTimeout, 默认 3s
+
+	MaxOpenConns    int           `json:"max_open_conns"` // 可选; 最大连接数, 默认不限制
+	MaxIdleConns    int           `json:"max_idle_conns"` // 可选; 最大空闲连接数, 默认 2
+	ConnMaxLifetime time.Duration `json:"-"`              // 可选; 连接最长存活时间, 默认不限制
+	ConnMaxIdleTime time.Duration `json:"-"`              // 可选; 连接最长空闲时间, 默认不限制
+
+	PingAttempts int           `json:"ping_attempts"` // 可选; 启动时 ping 的次数, 默认 3
+	PingBackoff  time.Duration `json:"-"`             // 可选; 第一次 ping 失败后的等待时间, 之后每次翻倍, 默认 1s
+
+	MigrateOnStart bool `json:"migrate_on_start"` // 可选; 启动时执行内置的数据库迁移, 见 Migrator
+
+	Logger logger.Logger `json:"-"` // 可选; 日志, 默认 logger.Default()
+}
+
+const (
+	defaultPort         = 3306
+	defaultCharset      = "utf8mb4"
+	defaultDialTimeout  = 5 * time.Second
+	defaultPingAttempts = 3
+	defaultPingBackoff  = time.Second
+)
+
+// LoadConfig 加载配置, path 不为空时先读取 json 文件, 然后使用 MYSQL_ 开头的环境变量覆盖.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	if path != "" {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(bs, &c); err != nil {
+			return c, fmt.Errorf("invalid mysql config file %s: %w", path, err)
+		}
+	}
+	if err := c.LoadEnv(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// LoadEnv 使用环境变量覆盖配置, 例如 MYSQL_HOST, MYSQL_PASSWORD, MYSQL_CONN_MAX_LIFETIME=1h.
+func (c *Config) LoadEnv() error {
+	strs := map[string]*string{
+		"MYSQL_HOST":     &c.Host,
+		"MYSQL_USER":     &c.User,
+		"MYSQL_PASSWORD": &c.Password,
+		"MYSQL_DATABASE": &c.Database,
+		"MYSQL_TLS":      &c.TLS,
+		"MYSQL_CHARSET":  &c.Charset,
+	}
+	for key, p := range strs {
+		if v, ok := os.LookupEnv(key); ok {
+			*p = v
+		}
+	}
+
+	ints := map[string]*int{
+		"MYSQL_PORT":           &c.Port,
+		"MYSQL_MAX_OPEN_CONNS": &c.MaxOpenConns,
+		"MYSQL_MAX_IDLE_CONNS": &c.MaxIdleConns,
+		"MYSQL_PING_ATTEMPTS":  &c.PingAttempts,
+	}
+	for key, p := range ints {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*p = n
+		}
+	}
+
+	for key, p := range c.durations("MYSQL_") {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*p = d
+		}
+	}
+
+	bools := map[string]*bool{
+		"MYSQL_PARSE_TIME":       &c.ParseTime,
+		"MYSQL_MIGRATE_ON_START": &c.MigrateOnStart,
+	}
+	for key, p := range bools {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*p = b
+		}
+	}
+	return nil
+}
+
+// durations 返回 duration 类型的配置项, key 为 prefix + 大写的 json 名.
+func (c *Config) durations(prefix string) map[string]*time.Duration {
+	return map[string]*time.Duration{
+		prefix + "TIMEOUT":            &c.Timeout,
+		prefix + "READ_TIMEOUT":       &c.ReadTimeout,
+		prefix + "WRITE_TIMEOUT":      &c.WriteTimeout,
+		prefix + "QUERY_TIMEOUT":      &c.QueryTimeout,
+		prefix + "CONN_MAX_LIFETIME":  &c.ConnMaxLifetime,
+		prefix + "CONN_MAX_IDLE_TIME": &c.ConnMaxIdleTime,
+		prefix + "PING_BACKOFF":       &c.PingBackoff,
+	}
+}
+
+// UnmarshalJSON 支持 "timeout": "5s" 格式的 duration.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config // 去掉 UnmarshalJSON 方法, 防止递归
+	if err := json.Unmarshal(data, (*config)(c)); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for key, p := range c.durations("") {
+		name := strings.ToLower(key)
+		v, ok := raw[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*p = d
+	}
+	return nil
+}
+
+// Validate 检查必须的配置项.
+func (c Config) Validate() error {
+	switch {
+	case c.Host == "":
+		return errors.New("empty mysql host")
+	case c.User == "":
+		return errors.New("empty mysql user")
+	case c.Database == "":
+		return errors.New("empty mysql database")
+	case c.Port < 0 || c.Port > 65535:
+		return errors.New("invalid mysql port")
+	}
+	return nil
+}
+
+// DSN 返回 go-sql-driver/mysql 的连接串.
+func (c Config) DSN() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	charset := c.Charset
+	if charset == "" {
+		charset = defaultCharset
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultDialTimeout
+	}
+
+	cfg := driver.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.Host, strconv.Itoa(port))
+	cfg.DBName = c.Database
+	cfg.TLSConfig = c.TLS
+	cfg.ParseTime = c.ParseTime
+	cfg.ClientFoundRows = true // update 时 RowsAffected 返回匹配的行数, 用于判断用户是否存在
+	cfg.Timeout = timeout
+	cfg.ReadTimeout = c.ReadTimeout
+	cfg.WriteTimeout = c.WriteTimeout
+	cfg.Params = map[string]string{"charset": charset}
+	return cfg.FormatDSN()
+}
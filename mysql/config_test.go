@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql.json")
+	content := `{"host": "db.local", "user": "app", "password": "file-secret", "database": "shm",
+		"parse_time": true, "max_open_conns": 20, "timeout": "3s", "conn_max_lifetime": "1h"}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MYSQL_PASSWORD", "env-secret")
+	t.Setenv("MYSQL_PORT", "3307")
+	t.Setenv("MYSQL_CONN_MAX_IDLE_TIME", "5m")
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if c.Host != "db.local" || c.Password != "env-secret" || c.Port != 3307 || !c.ParseTime || c.MaxOpenConns != 20 {
+		t.Errorf("LoadConfig() = %+v", c)
+	}
+	if c.Timeout != 3*time.Second || c.ConnMaxLifetime != time.Hour || c.ConnMaxIdleTime != 5*time.Minute {
+		t.Errorf("LoadConfig() durations = %v %v %v", c.Timeout, c.ConnMaxLifetime, c.ConnMaxIdleTime)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	want := "app:env-secret@tcp(db.local:3307)/shm?clientFoundRows=true&parseTime=true&timeout=3s&charset=utf8mb4"
+	if got := c.DSN(); got != want {
+		t.Errorf("DSN() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	t.Setenv("MYSQL_PORT", "abc")
+	if _, err := LoadConfig(""); err == nil {
+		t.Errorf("LoadConfig() error = nil, want invalid MYSQL_PORT")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "empty host", config: Config{User: "app", Database: "shm"}},
+		{name: "empty user", config: Config{Host: "db", Database: "shm"}},
+		{name: "empty database", config: Config{Host: "db", User: "app"}},
+		{name: "invalid port", config: Config{Host: "db", User: "app", Database: "shm", Port: 70000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err == nil {
+				t.Errorf("Validate() error = nil")
+			}
+		})
+	}
+}
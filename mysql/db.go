@@ -1,17 +1,80 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"demo-to-start/logger"
+	"fmt"
+	"time"
+
 	_ "github.com/go-sql-driver/mysql" // 注册驱动，不需要内部方法
 )
 
-var db *sql.DB
+// Open 按 config 创建数据库连接池, 并且 ping 数据库, 数据库不可用时返回错误.
+//
+// NOTE: 不要忘记调用 sql.DB.Close, 否则会有资源泄漏.
+func Open(ctx context.Context, config Config) (*sql.DB, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
 
-func RegisterDB() error {
-	var err error
-	db, err = sql.Open("mysql", "root:root@tcp(10.105.11.29:3306)/shm")
+	conn, err := sql.Open("mysql", config.DSN())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	conn.SetMaxOpenConns(config.MaxOpenConns)
+	if config.MaxIdleConns != 0 {
+		conn.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(config.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if err := ping(ctx, conn, config); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ping 按指数退避重试 ping 数据库, 直到成功或者用完 PingAttempts 次.
+func ping(ctx context.Context, conn *sql.DB, config Config) error {
+	attempts := config.PingAttempts
+	if attempts <= 0 {
+		attempts = defaultPingAttempts
+	}
+	backoff := config.PingBackoff
+	if backoff <= 0 {
+		backoff = defaultPingBackoff
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+
+	var err error
+	for i := 1; ; i++ {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = conn.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		config.Logger.Warn(ctx, "mysql-ping-failed", "host", config.Host, "database", config.Database, "attempt", i, "error", err.Error())
+		if i >= attempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
 	}
-	return nil
-}
\ No newline at end of file
+	return fmt.Errorf("mysql %s/%s unreachable after %d attempts: %w", config.Host, config.Database, attempts, err)
+}
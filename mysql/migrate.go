@@ -0,0 +1,426 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"demo-to-start/logger"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations 是内置的数据库迁移文件, 文件名格式为 <version>_<name>.up.sql 和 <version>_<name>.down.sql.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// MigrationsDir 是内置迁移文件在仓库中的目录, migrate create 默认在这里生成文件.
+const MigrationsDir = "mysql/migrations"
+
+const (
+	defaultMigrationTable = "schema_migrations"
+	defaultLockTimeout    = 10 * time.Second
+)
+
+var (
+	migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	migrationNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Migration 是一个版本的迁移, Down 没有语句 (为空或者只有注释) 时不能回滚.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 是一个版本的迁移状态.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time // Applied 为 true 时有效
+	Missing   bool      // 已执行但是找不到对应的迁移文件
+}
+
+// LoadMigrations 从 fsys 的根目录读取迁移文件, 按版本号升序返回.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		m := migrationFileRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %s, want <version>_<name>.up.sql or <version>_<name>.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		bs, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(bs)
+		} else {
+			migration.Down = string(bs)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up sql", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// CreateMigration 在 dir 下创建下一个版本的迁移文件, 返回 up 和 down 文件的路径.
+// 文件内容是只有注释的模板, 可以正常加载, 没有编写 up 的 SQL 之前 Migrator.Up 返回错误, 不会记录为已执行.
+func CreateMigration(dir, name string) (up, down string, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !migrationNameRe.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q, only [a-z0-9_] are allowed", name)
+	}
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", fmt.Errorf("load migrations in %s: %w", dir, err)
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down = prefix+".up.sql", prefix+".down.sql"
+	templates := map[string]string{
+		up:   "-- 在这里编写升级的 SQL, 每条语句以分号结尾.\n",
+		down: "-- 在这里编写回滚的 SQL, 按和 up 相反的顺序撤销修改; 不能回滚时只保留注释.\n",
+	}
+	for _, p := range []string{up, down} {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = f.WriteString(templates[p])
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
+
+// MigratorConfig 是数据库迁移相关配置.
+type MigratorConfig struct {
+	DB          *sql.DB       // 必须; 通过 Open 创建的连接池
+	FS          fs.FS         // 可选; 迁移文件, 默认内置的 Migrations
+	Table       string        // 可选; 记录已执行版本的表, 默认 schema_migrations
+	LockTimeout time.Duration // 可选; 等待其他实例执行迁移的时间, 默认 10s
+	Logger      logger.Logger // 可选; 日志, 默认 logger.Default()
+}
+
+// Migrator 执行数据库迁移, 多个实例同时执行时通过 GET_LOCK 互斥.
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	table       string
+	lockTimeout time.Duration
+	logger      logger.Logger
+}
+
+// NewMigrator 创建 Migrator, 迁移文件不合法时返回错误.
+func NewMigrator(config MigratorConfig) (*Migrator, error) {
+	if config.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	if config.FS == nil {
+		sub, err := fs.Sub(Migrations, "migrations")
+		if err != nil {
+			return nil, err
+		}
+		config.FS = sub
+	}
+	if config.Table == "" {
+		config.Table = defaultMigrationTable
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = defaultLockTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	migrations, err := LoadMigrations(config.FS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          config.DB,
+		migrations:  migrations,
+		table:       config.Table,
+		lockTimeout: config.LockTimeout,
+		logger:      config.Logger,
+	}, nil
+}
+
+// Up 按版本号升序执行所有未执行的迁移, 返回本次执行的迁移.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			// migrate create 生成的模板还没有编写, 不能记录为已执行
+			if len(splitStatements(migration.Up)) == 0 {
+				return fmt.Errorf("migration %d_%s has no up sql, fill in %04d_%s.up.sql", migration.Version, migration.Name, migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, conn, migration, migration.Up, true); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本号降序回滚最近执行的 steps 个迁移, 返回本次回滚的迁移.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("invalid steps %d", steps)
+	}
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			migration, ok := byVersion[versions[i]]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but not found", versions[i])
+			}
+			if len(splitStatements(migration.Down)) == 0 {
+				return fmt.Errorf("migration %d_%s has no down sql", migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, conn, migration, migration.Down, false); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回所有迁移的状态, 按版本号升序.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if s, ok := applied[migration.Version]; ok {
+			status.Applied, status.AppliedAt = true, s.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, s := range applied {
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// withLock 在同一个连接上获取 GET_LOCK 并执行 fn, 保证同一时间只有一个实例执行迁移.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// GET_LOCK 的锁属于连接, 所以加锁, 迁移和解锁都必须使用同一个连接; 锁是整个实例共享的, 锁名带上库名
+	lockName := m.table
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)", lockName, int(m.lockTimeout/time.Second)).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("get migration lock: %w", err)
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return fmt.Errorf("get migration lock: another migration is running, waited %s", m.lockTimeout)
+	}
+	defer func() {
+		// ctx 可能已经取消, 使用新的 ctx 释放锁, 连接关闭时锁也会被释放
+		releaseCtx, cancel := context.WithTimeout(context.Background(), m.lockTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(releaseCtx, "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))", lockName); err != nil {
+			m.logger.Warn(ctx, "mysql-release-migration-lock-failed", "error", err.Error())
+		}
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+m.table+"` ("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`applied_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"+
+		") ENGINE = InnoDB DEFAULT CHARSET = utf8mb4")
+	return err
+}
+
+// applied 返回已执行的版本, 迁移表不存在时返回空.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]MigrationStatus, error) {
+	var exists int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", m.table).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]MigrationStatus)
+	if exists == 0 {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT `version`, `name`, UNIX_TIMESTAMP(`applied_at`) FROM `"+m.table+"`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			s         = MigrationStatus{Applied: true, Missing: true}
+			appliedAt int64
+		)
+		if err := rows.Scan(&s.Version, &s.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		s.AppliedAt = time.Unix(appliedAt, 0)
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// apply 逐条执行 sql 并记录版本. mysql 的 DDL 会隐式提交, 执行到一半失败时需要人工处理.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, script string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	start := time.Now()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			m.logger.Error(ctx, "mysql-migration-failed", "version", migration.Version, "name", migration.Name, "direction", direction, "error", err.Error())
+			return fmt.Errorf("migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+		}
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO `"+m.table+"` (`version`, `name`) VALUES (?, ?)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM `"+m.table+"` WHERE `version` = ?", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.logger.Info(ctx, "mysql-migration-applied", "version", migration.Version, "name", migration.Name, "direction", direction, "elapsed", time.Since(start).String())
+	return nil
+}
+
+// splitStatements 按行尾的分号拆分 sql, 去掉空语句和只有 -- 注释的语句.
+// 不支持语句中间行尾带分号的字符串和存储过程.
+func splitStatements(script string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+	)
+	flush := func() {
+		stmt := strings.TrimSpace(buf.String())
+		buf.Reset()
+		for _, line := range strings.Split(stmt, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--") {
+				stmts = append(stmts, stmt)
+				return
+			}
+		}
+	}
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(trimmed, ";") {
+			buf.WriteString(strings.TrimSuffix(trimmed, ";"))
+			flush()
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+	return stmts
+}
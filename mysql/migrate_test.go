@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE `users` ADD `email` VARCHAR(128);")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE `users` (`id` INT);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE `users`;")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+	got, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "create_users", Up: "CREATE TABLE `users` (`id` INT);", Down: "DROP TABLE `users`;"},
+		{Version: 2, Name: "add_email", Up: "ALTER TABLE `users` ADD `email` VARCHAR(128);"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadMigrations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "invalid file name", fsys: fstest.MapFS{"create_users.up.sql": {Data: []byte("SELECT 1;")}}},
+		{name: "zero version", fsys: fstest.MapFS{"0000_create_users.up.sql": {Data: []byte("SELECT 1;")}}},
+		{name: "duplicate version", fsys: fstest.MapFS{
+			"0001_create_users.up.sql": {Data: []byte("SELECT 1;")},
+			"0001_create_posts.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{name: "down without up", fsys: fstest.MapFS{"0001_create_users.down.sql": {Data: []byte("SELECT 1;")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tt.fsys); err == nil {
+				t.Errorf("LoadMigrations() error = nil")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	for _, m := range migrations {
+		if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+			t.Errorf("migration %d_%s should have both up and down sql", m.Version, m.Name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- create table\nCREATE TABLE `a` (\n  `id` INT\n);\n\n-- only a comment;\nINSERT INTO `a` VALUES (1);  \nSELECT 1"
+	want := []string{"-- create table\nCREATE TABLE `a` (\n  `id` INT\n)", "INSERT INTO `a` VALUES (1)", "SELECT 1"}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0001_create_users.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	up, down, err := CreateMigration(dir, "Add_Email")
+	if err != nil {
+		t.Fatalf("CreateMigration() error = %v", err)
+	}
+	if up != filepath.Join(dir, "0002_add_email.up.sql") || down != filepath.Join(dir, "0002_add_email.down.sql") {
+		t.Errorf("CreateMigration() = %s, %s", up, down)
+	}
+	// 生成的模板可以加载, 但是没有语句, 编写之前不会被执行
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("LoadMigrations() after CreateMigration error = %v", err)
+	}
+	if len(migrations) != 2 || migrations[1].Name != "add_email" || len(splitStatements(migrations[1].Up)) != 0 || len(splitStatements(migrations[1].Down)) != 0 {
+		t.Errorf("LoadMigrations() = %+v", migrations)
+	}
+	// 没有编写 SQL 也可以继续创建下一个版本
+	if up, _, err := CreateMigration(dir, "add_phone"); err != nil || up != filepath.Join(dir, "0003_add_phone.up.sql") {
+		t.Errorf("second CreateMigration() = %s, %v", up, err)
+	}
+
+	if _, _, err := CreateMigration(dir, "add email"); err == nil {
+		t.Errorf("CreateMigration() with invalid name error = nil")
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `users` (
+    `id`   INT         NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(64) NOT NULL,
+    `age`  INT         NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
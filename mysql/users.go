@@ -1,18 +1,179 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
+	"demo-to-start/logger"
 	"demo-to-start/model"
-	"log"
+	"demo-to-start/trace"
+	"errors"
+	"time"
 )
 
-// GetUser 数据库查询
-func GetUser(userID int) (model.User, error) {
-	var res model.User
+// userColumns 是 users 表的列, 和 scanUser 的顺序保持一致, 不使用 select * 防止表结构变化之后 Scan 出错.
+const userColumns = "`id`, `name`, `age`"
+
+const defaultQueryTimeout = 3 * time.Second
+
+// UserRepositoryConfig 是 mysql UserRepository 相关配置.
+type UserRepositoryConfig struct {
+	DB           *sql.DB       // 必须; 通过 Open 创建的连接池
+	Logger       logger.Logger // 可选; 日志, 默认 logger.Default()
+	QueryTimeout time.Duration // 可选; 单次查询的超时时间, 默认 3s, 请求的 ctx 更早超时时以 ctx 为准
+}
+
+// NewUserRepository 创建一个基于 mysql users 表的 model.UserRepository.
+func NewUserRepository(config UserRepositoryConfig) (model.UserRepository, error) {
+	if config.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	if config.QueryTimeout <= 0 {
+		config.QueryTimeout = defaultQueryTimeout
+	}
+	return &userRepository{db: config.DB, logger: config.Logger, queryTimeout: config.QueryTimeout}, nil
+}
+
+type userRepository struct {
+	db           *sql.DB
+	logger       logger.Logger
+	queryTimeout time.Duration
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser 按 userColumns 的顺序读取一行.
+func scanUser(row rowScanner) (model.User, error) {
+	var user model.User
+	err := row.Scan(&user.ID, &user.Name, &user.Age)
+	return user, err
+}
+
+// Get 数据库查询
+func (impl *userRepository) Get(ctx context.Context, userID int) (model.User, error) {
+	ctx, span := trace.Start(ctx, "mysql.GetUser", "db.system", "mysql", "db.table", "users", "user_id", userID)
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, impl.queryTimeout)
+	defer cancel()
+
 	// 这个是单行返回的方法,Scan指定列与字段对应顺序
-	err := db.QueryRow("select * from `users` where id=? ", userID).Scan(&res.ID, &res.Name, &res.Age)
+	res, err := scanUser(impl.db.QueryRowContext(queryCtx, "select "+userColumns+" from `users` where `id`=?", userID))
 	if err != nil {
-		log.Println(err)
+		impl.fail(ctx, span, "mysql-get-user-failed", err, "user_id", userID)
 		return res, err
 	}
 	return res, nil
 }
+
+func (impl *userRepository) List(ctx context.Context, offset, limit int) ([]model.User, error) {
+	ctx, span := trace.Start(ctx, "mysql.ListUsers", "db.system", "mysql", "db.table", "users", "offset", offset, "limit", limit)
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, impl.queryTimeout)
+	defer cancel()
+
+	rows, err := impl.db.QueryContext(queryCtx, "select "+userColumns+" from `users` order by `id` limit ? offset ?", limit, offset)
+	if err != nil {
+		impl.fail(ctx, span, "mysql-list-users-failed", err, "offset", offset, "limit", limit)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0, limit)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			impl.fail(ctx, span, "mysql-list-users-failed", err, "offset", offset, "limit", limit)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		impl.fail(ctx, span, "mysql-list-users-failed", err, "offset", offset, "limit", limit)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (impl *userRepository) Create(ctx context.Context, user model.User) (model.User, error) {
+	ctx, span := trace.Start(ctx, "mysql.CreateUser", "db.system", "mysql", "db.table", "users")
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, impl.queryTimeout)
+	defer cancel()
+
+	result, err := impl.db.ExecContext(queryCtx, "insert into `users` (`name`, `age`) values (?, ?)", user.Name, user.Age)
+	if err != nil {
+		impl.fail(ctx, span, "mysql-create-user-failed", err)
+		return user, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		impl.fail(ctx, span, "mysql-create-user-failed", err)
+		return user, err
+	}
+	user.ID = int(id)
+	return user, nil
+}
+
+func (impl *userRepository) Update(ctx context.Context, user model.User) error {
+	ctx, span := trace.Start(ctx, "mysql.UpdateUser", "db.system", "mysql", "db.table", "users", "user_id", user.ID)
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, impl.queryTimeout)
+	defer cancel()
+
+	result, err := impl.db.ExecContext(queryCtx, "update `users` set `name`=?, `age`=? where `id`=?", user.Name, user.Age, user.ID)
+	if err == nil {
+		err = checkAffected(result)
+	}
+	if err != nil {
+		impl.fail(ctx, span, "mysql-update-user-failed", err, "user_id", user.ID)
+		return err
+	}
+	return nil
+}
+
+func (impl *userRepository) Delete(ctx context.Context, userID int) error {
+	ctx, span := trace.Start(ctx, "mysql.DeleteUser", "db.system", "mysql", "db.table", "users", "user_id", userID)
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, impl.queryTimeout)
+	defer cancel()
+
+	result, err := impl.db.ExecContext(queryCtx, "delete from `users` where `id`=?", userID)
+	if err == nil {
+		err = checkAffected(result)
+	}
+	if err != nil {
+		impl.fail(ctx, span, "mysql-delete-user-failed", err, "user_id", userID)
+		return err
+	}
+	return nil
+}
+
+// fail 记录失败的查询, 用户不存在属于正常情况, 只记录 span 不打印日志.
+func (impl *userRepository) fail(ctx context.Context, span *trace.Span, msg string, err error, keyvals ...interface{}) {
+	span.RecordError(err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	impl.logger.Error(ctx, msg, append(keyvals, "error", err.Error())...)
+}
+
+// checkAffected 没有匹配的行时返回 sql.ErrNoRows, 连接串设置了 clientFoundRows, 值未改变的行也算匹配.
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
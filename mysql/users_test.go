@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"demo-to-start/logger"
+	"demo-to-start/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingConnector 创建的连接执行任何查询都阻塞到 ctx 结束, 用于模拟慢查询.
+type blockingConnector struct{}
+
+func (blockingConnector) Connect(context.Context) (driver.Conn, error) { return blockingConn{}, nil }
+func (blockingConnector) Driver() driver.Driver                        { return nil }
+
+type blockingConn struct{}
+
+func (blockingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (blockingConn) Close() error                        { return nil }
+func (blockingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestUserRepository_QueryTimeout(t *testing.T) {
+	db := sql.OpenDB(blockingConnector{})
+	defer db.Close()
+	users, err := NewUserRepository(UserRepositoryConfig{DB: db, Logger: logger.Nop(), QueryTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{name: "get", call: func(ctx context.Context) error { _, err := users.Get(ctx, 1); return err }},
+		{name: "list", call: func(ctx context.Context) error { _, err := users.List(ctx, 0, 10); return err }},
+		{name: "create", call: func(ctx context.Context) error { _, err := users.Create(ctx, model.User{Name: "alice"}); return err }},
+		{name: "update", call: func(ctx context.Context) error { return users.Update(ctx, model.User{ID: 1, Name: "alice"}) }},
+		{name: "delete", call: func(ctx context.Context) error { return users.Delete(ctx, 1) }},
+	}
+	for _, op := range ops {
+		t.Run(op.name, func(t *testing.T) {
+			begin := time.Now()
+			if err := op.call(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(begin); elapsed > time.Second {
+				t.Errorf("returned after %v, want about QueryTimeout", elapsed)
+			}
+
+			// 请求的 ctx 更早结束时以 ctx 为准
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := op.call(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("error with canceled ctx = %v, want context.Canceled", err)
+			}
+		})
+	}
+}
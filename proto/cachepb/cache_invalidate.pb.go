@@ -0,0 +1,184 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.25.0
+// 	protoc        v3.14.0
+// source: cache_invalidate.proto
+
+package cachepb
+
+import (
+	proto "github.com/golang/protobuf/proto"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+// CacheInvalidate 通知所有实例删除本地缓存中的 key.
+type CacheInvalidate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 缓存名称, 例如 users.
+	Cache string `protobuf:"bytes,1,opt,name=cache,proto3" json:"cache,omitempty"`
+	// 需要删除的 key, 为空时删除该缓存的全部 key.
+	Keys []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	// 发送方的实例标识, 实例收到自己发出的消息时忽略.
+	InstanceId string `protobuf:"bytes,3,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	// 发送时间, unix 毫秒.
+	TimestampMs int64 `protobuf:"varint,4,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+}
+
+func (x *CacheInvalidate) Reset() {
+	*x = CacheInvalidate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cache_invalidate_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CacheInvalidate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CacheInvalidate) ProtoMessage() {}
+
+func (x *CacheInvalidate) ProtoReflect() protoreflect.Message {
+	mi := &file_cache_invalidate_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CacheInvalidate.ProtoReflect.Descriptor instead.
+func (*CacheInvalidate) Descriptor() ([]byte, []int) {
+	return file_cache_invalidate_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CacheInvalidate) GetCache() string {
+	if x != nil {
+		return x.Cache
+	}
+	return ""
+}
+
+func (x *CacheInvalidate) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *CacheInvalidate) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *CacheInvalidate) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+var File_cache_invalidate_proto protoreflect.FileDescriptor
+
+var file_cache_invalidate_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x63, 0x61, 0x63, 0x68, 0x65, 0x5f, 0x69, 0x6e, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61,
+	0x74, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0d, 0x64, 0x65, 0x6d, 0x6f, 0x2e, 0x63,
+	0x61, 0x63, 0x68, 0x65, 0x2e, 0x76, 0x31, 0x22, 0x7f, 0x0a, 0x0f, 0x43, 0x61, 0x63, 0x68, 0x65,
+	0x49, 0x6e, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x61,
+	0x63, 0x68, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x61, 0x63, 0x68, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04,
+	0x6b, 0x65, 0x79, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x69, 0x6e, 0x73, 0x74, 0x61,
+	0x6e, 0x63, 0x65, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x5f, 0x6d, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x4d, 0x73, 0x42, 0x25, 0x5a, 0x23, 0x64, 0x65, 0x6d, 0x6f,
+	0x2d, 0x74, 0x6f, 0x2d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f,
+	0x63, 0x61, 0x63, 0x68, 0x65, 0x70, 0x62, 0x3b, 0x63, 0x61, 0x63, 0x68, 0x65, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_cache_invalidate_proto_rawDescOnce sync.Once
+	file_cache_invalidate_proto_rawDescData = file_cache_invalidate_proto_rawDesc
+)
+
+func file_cache_invalidate_proto_rawDescGZIP() []byte {
+	file_cache_invalidate_proto_rawDescOnce.Do(func() {
+		file_cache_invalidate_proto_rawDescData = protoimpl.X.CompressGZIP(file_cache_invalidate_proto_rawDescData)
+	})
+	return file_cache_invalidate_proto_rawDescData
+}
+
+var file_cache_invalidate_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_cache_invalidate_proto_goTypes = []interface{}{
+	(*CacheInvalidate)(nil), // 0: demo.cache.v1.CacheInvalidate
+}
+var file_cache_invalidate_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_cache_invalidate_proto_init() }
+func file_cache_invalidate_proto_init() {
+	if File_cache_invalidate_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_cache_invalidate_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CacheInvalidate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_cache_invalidate_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_cache_invalidate_proto_goTypes,
+		DependencyIndexes: file_cache_invalidate_proto_depIdxs,
+		MessageInfos:      file_cache_invalidate_proto_msgTypes,
+	}.Build()
+	File_cache_invalidate_proto = out.File
+	file_cache_invalidate_proto_rawDesc = nil
+	file_cache_invalidate_proto_goTypes = nil
+	file_cache_invalidate_proto_depIdxs = nil
+}
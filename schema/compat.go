@@ -0,0 +1,274 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"demo-to-start/kafka"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Mode 是兼容性规则.
+type Mode int
+
+const (
+	// Backward 新的 schema 可以读取旧 schema 写入的数据, 即先升级 consumer.
+	Backward Mode = 1 << iota
+	// Forward 旧的 schema 可以读取新 schema 写入的数据, 即先升级 producer.
+	Forward
+	// Full 同时满足 Backward 和 Forward.
+	Full = Backward | Forward
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Backward:
+		return "backward"
+	case Forward:
+		return "forward"
+	case Full:
+		return "full"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseMode 解析 backward/forward/full.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "backward":
+		return Backward, nil
+	case "forward":
+		return Forward, nil
+	case "full":
+		return Full, nil
+	default:
+		return 0, fmt.Errorf("invalid compatibility mode %q", s)
+	}
+}
+
+// Violation 是一条不兼容的变更.
+type Violation struct {
+	MessageType kafka.MessageType // 注册的消息类型
+	Message     string            // 发生变更的 message 全名, 可能是 MessageType 引用的嵌套 message
+	Field       string            // 发生变更的字段或枚举值, 可能为空
+	Rule        string            // 违反的规则, 例如 field-type-changed
+	Mode        Mode              // 在哪些规则下不兼容
+	Detail      string
+}
+
+func (v Violation) String() string {
+	target := v.Message
+	if v.Field != "" {
+		target += "." + v.Field
+	}
+	return fmt.Sprintf("message type %s: %s: %s (%s, breaks %s compatibility)", v.MessageType.String(), target, v.Detail, v.Rule, v.Mode)
+}
+
+// Check 按 mode 检查 types 中每个 MessageType 对应的 message 从 old 到 new 的变更是否兼容.
+//
+// types 是 MessageType 到 proto message 全名的映射, 例如 {1: "demo.UserCreated"}.
+func Check(old, new *descriptorpb.FileDescriptorSet, types map[kafka.MessageType]string, mode Mode) ([]Violation, error) {
+	oldFiles, err := protodesc.NewFiles(old)
+	if err != nil {
+		return nil, fmt.Errorf("invalid old descriptor set: %w", err)
+	}
+	newFiles, err := protodesc.NewFiles(new)
+	if err != nil {
+		return nil, fmt.Errorf("invalid new descriptor set: %w", err)
+	}
+
+	msgTypes := make([]kafka.MessageType, 0, len(types))
+	for msgType := range types {
+		msgTypes = append(msgTypes, msgType)
+	}
+	sort.Slice(msgTypes, func(i, j int) bool { return msgTypes[i] < msgTypes[j] })
+
+	var violations []Violation
+	for _, msgType := range msgTypes {
+		vs, err := CheckMessage(oldFiles, newFiles, msgType, protoreflect.FullName(types[msgType]), mode)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, vs...)
+	}
+	return violations, nil
+}
+
+// CheckMessage 按 mode 检查 name 对应的 message 以及它引用的 message 和 enum 从 old 到 new 的变更是否兼容.
+func CheckMessage(old, new *protoregistry.Files, msgType kafka.MessageType, name protoreflect.FullName, mode Mode) ([]Violation, error) {
+	oldMsg, err := findMessage(old, name)
+	if err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	c := &checker{msgType: msgType, mode: mode, visited: make(map[protoreflect.FullName]bool)}
+	newMsg, err := findMessage(new, name)
+	if err != nil {
+		c.report(Full, string(name), "", "message-removed", "message is removed")
+		return c.violations, nil
+	}
+	c.checkMessage(oldMsg, newMsg)
+	return c.violations, nil
+}
+
+func findMessage(files *protoregistry.Files, name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	d, err := files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found", name)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.New(string(name) + " is not a message")
+	}
+	return md, nil
+}
+
+type checker struct {
+	msgType    kafka.MessageType
+	mode       Mode
+	visited    map[protoreflect.FullName]bool
+	violations []Violation
+}
+
+// report 记录一条违反规则的变更, breaks 是该变更不兼容的规则, 和 mode 没有交集时忽略.
+func (c *checker) report(breaks Mode, message, field, rule, detail string) {
+	if breaks&c.mode == 0 {
+		return
+	}
+	c.violations = append(c.violations, Violation{
+		MessageType: c.msgType,
+		Message:     message,
+		Field:       field,
+		Rule:        rule,
+		Mode:        breaks & c.mode,
+		Detail:      detail,
+	})
+}
+
+func (c *checker) checkMessage(old, new protoreflect.MessageDescriptor) {
+	if c.visited[old.FullName()] {
+		return
+	}
+	c.visited[old.FullName()] = true
+	message := string(old.FullName())
+
+	oldFields, newFields := old.Fields(), new.Fields()
+	for i := 0; i < oldFields.Len(); i++ {
+		of := oldFields.Get(i)
+		nf := newFields.ByNumber(of.Number())
+		if renumbered := newFields.ByName(of.Name()); renumbered != nil && renumbered.Number() != of.Number() {
+			c.report(Full, message, string(of.Name()), "field-renumbered",
+				fmt.Sprintf("field number changed from %d to %d", of.Number(), renumbered.Number()))
+			continue
+		}
+		if nf == nil {
+			if !new.ReservedRanges().Has(of.Number()) {
+				c.report(Full, message, string(of.Name()), "field-removed-not-reserved",
+					fmt.Sprintf("field %d is removed without reserving its number", of.Number()))
+			}
+			if of.Cardinality() == protoreflect.Required {
+				c.report(Forward, message, string(of.Name()), "required-field-removed", "required field is removed")
+			}
+			continue
+		}
+		c.checkField(message, of, nf)
+	}
+
+	for i := 0; i < newFields.Len(); i++ {
+		nf := newFields.Get(i)
+		if oldFields.ByNumber(nf.Number()) != nil {
+			continue
+		}
+		if old.ReservedRanges().Has(nf.Number()) || old.ReservedNames().Has(nf.Name()) {
+			c.report(Full, message, string(nf.Name()), "reserved-field-reused",
+				fmt.Sprintf("field %d reuses a reserved number or name", nf.Number()))
+		}
+		if nf.Cardinality() == protoreflect.Required {
+			c.report(Backward, message, string(nf.Name()), "required-field-added", "required field is added")
+		}
+	}
+}
+
+func (c *checker) checkField(message string, old, new protoreflect.FieldDescriptor) {
+	field := string(old.Name())
+	if !wireCompatible(old.Kind(), new.Kind()) {
+		c.report(Full, message, field, "field-type-changed",
+			fmt.Sprintf("field %d type changed from %s to %s", old.Number(), old.Kind(), new.Kind()))
+		return
+	}
+	if old.IsMap() != new.IsMap() || (old.Cardinality() == protoreflect.Repeated) != (new.Cardinality() == protoreflect.Repeated) {
+		c.report(Full, message, field, "field-cardinality-changed",
+			fmt.Sprintf("field %d cardinality changed from %s to %s", old.Number(), old.Cardinality(), new.Cardinality()))
+		return
+	}
+	if old.Cardinality() != protoreflect.Required && new.Cardinality() == protoreflect.Required {
+		c.report(Backward, message, field, "field-made-required", "field becomes required")
+	}
+	if old.Cardinality() == protoreflect.Required && new.Cardinality() != protoreflect.Required {
+		c.report(Forward, message, field, "field-made-optional", "field is no longer required")
+	}
+
+	switch {
+	case old.IsMap():
+		c.checkField(message, old.MapValue(), new.MapValue())
+	case old.Message() != nil && new.Message() != nil:
+		if old.Message().FullName() != new.Message().FullName() {
+			c.report(Full, message, field, "field-message-changed",
+				fmt.Sprintf("field %d message changed from %s to %s", old.Number(), old.Message().FullName(), new.Message().FullName()))
+			return
+		}
+		c.checkMessage(old.Message(), new.Message())
+	case old.Enum() != nil && new.Enum() != nil:
+		c.checkEnum(old.Enum(), new.Enum())
+	}
+}
+
+func (c *checker) checkEnum(old, new protoreflect.EnumDescriptor) {
+	if c.visited[old.FullName()] {
+		return
+	}
+	c.visited[old.FullName()] = true
+
+	values := old.Values()
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if new.Values().ByNumber(v.Number()) != nil || new.ReservedRanges().Has(v.Number()) {
+			continue
+		}
+		// 新的 schema 读到旧数据中被删除的枚举值
+		c.report(Backward, string(old.FullName()), string(v.Name()), "enum-value-removed",
+			fmt.Sprintf("enum value %d is removed without reserving its number", v.Number()))
+	}
+}
+
+// wireCompatible 返回两个字段类型在 wire format 上是否兼容.
+func wireCompatible(old, new protoreflect.Kind) bool {
+	if old == new {
+		return true
+	}
+	group := func(k protoreflect.Kind) int {
+		switch k {
+		case protoreflect.Int32Kind, protoreflect.Uint32Kind, protoreflect.Int64Kind, protoreflect.Uint64Kind,
+			protoreflect.BoolKind, protoreflect.EnumKind:
+			return 1
+		case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+			return 2
+		case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+			return 3
+		case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+			return 4
+		case protoreflect.StringKind, protoreflect.BytesKind:
+			return 5
+		default:
+			return 0
+		}
+	}
+	g := group(old)
+	return g != 0 && g == group(new)
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"testing"
+
+	"demo-to-start/kafka"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+type testField struct {
+	name     string
+	number   int32
+	typ      descriptorpb.FieldDescriptorProto_Type
+	repeated bool
+}
+
+func userSet(reserved []int32, fields ...testField) *descriptorpb.FileDescriptorSet {
+	msg := &descriptorpb.DescriptorProto{Name: proto.String("User")}
+	for _, f := range fields {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if f.repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		msg.Field = append(msg.Field, &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(f.name),
+			JsonName: proto.String(f.name),
+			Number:   proto.Int32(f.number),
+			Label:    label.Enum(),
+			Type:     f.typ.Enum(),
+		})
+	}
+	for _, n := range reserved {
+		msg.ReservedRange = append(msg.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{Start: proto.Int32(n), End: proto.Int32(n + 1)})
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("user.proto"),
+		Package:     proto.String("demo"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{msg},
+	}}}
+}
+
+var (
+	typeString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	typeBytes  = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+	typeInt32  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	typeInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	typeDouble = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+)
+
+func TestCheck(t *testing.T) {
+	base := userSet(nil, testField{"id", 1, typeInt64, false}, testField{"name", 2, typeString, false})
+	tests := []struct {
+		name      string
+		new       *descriptorpb.FileDescriptorSet
+		mode      Mode
+		wantRules []string
+	}{
+		{name: "unchanged", new: base, mode: Full},
+		{name: "field added", new: userSet(nil, testField{"id", 1, typeInt64, false}, testField{"name", 2, typeString, false}, testField{"age", 3, typeInt32, false}), mode: Full},
+		{name: "wire compatible type", new: userSet(nil, testField{"id", 1, typeInt32, false}, testField{"name", 2, typeBytes, false}), mode: Full},
+		{name: "type changed", new: userSet(nil, testField{"id", 1, typeDouble, false}, testField{"name", 2, typeString, false}), mode: Backward, wantRules: []string{"field-type-changed"}},
+		{name: "renumbered", new: userSet(nil, testField{"id", 1, typeInt64, false}, testField{"name", 3, typeString, false}), mode: Forward, wantRules: []string{"field-renumbered"}},
+		{name: "swapped numbers", new: userSet(nil, testField{"id", 2, typeInt64, false}, testField{"name", 1, typeString, false}), mode: Full, wantRules: []string{"field-renumbered", "field-renumbered"}},
+		{name: "removed not reserved", new: userSet(nil, testField{"id", 1, typeInt64, false}), mode: Backward, wantRules: []string{"field-removed-not-reserved"}},
+		{name: "removed and reserved", new: userSet([]int32{2}, testField{"id", 1, typeInt64, false}), mode: Full},
+		{name: "cardinality changed", new: userSet(nil, testField{"id", 1, typeInt64, false}, testField{"name", 2, typeString, true}), mode: Full, wantRules: []string{"field-cardinality-changed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := Check(base, tt.new, map[kafka.MessageType]string{1: "demo.User"}, tt.mode)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if len(violations) != len(tt.wantRules) {
+				t.Fatalf("Check() = %v, want rules %v", violations, tt.wantRules)
+			}
+			for i, v := range violations {
+				if v.Rule != tt.wantRules[i] || v.MessageType != 1 {
+					t.Errorf("Check()[%d] = %v, want rule %v", i, v, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_ReservedReused(t *testing.T) {
+	old := userSet([]int32{2}, testField{"id", 1, typeInt64, false})
+	new := userSet(nil, testField{"id", 1, typeInt64, false}, testField{"email", 2, typeString, false})
+	violations, err := Check(old, new, map[kafka.MessageType]string{1: "demo.User"}, Full)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(violations) != 1 || violations[0].Rule != "reserved-field-reused" {
+		t.Errorf("Check() = %v, want reserved-field-reused", violations)
+	}
+}
+
+func TestCheck_MessageRemoved(t *testing.T) {
+	old := userSet(nil, testField{"id", 1, typeInt64, false})
+	new := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{Name: proto.String("user.proto"), Package: proto.String("demo")}}}
+	violations, err := Check(old, new, map[kafka.MessageType]string{7: "demo.User"}, Backward)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(violations) != 1 || violations[0].Rule != "message-removed" || violations[0].Mode != Backward {
+		t.Errorf("Check() = %v, want message-removed", violations)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{Backward, Forward, Full} {
+		if got, err := ParseMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseMode("none"); err == nil {
+		t.Errorf("ParseMode(none) error = nil")
+	}
+}
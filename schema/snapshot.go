@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"demo-to-start/kafka"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// RegistryFile 是 schema 目录下登记 MessageType 的文件名.
+const RegistryFile = "registry.json"
+
+// Registry 登记需要检查兼容性的 MessageType, 保存在 schema 目录的 registry.json 中.
+//
+//	{"mode": "backward", "message_types": {"1": "demo.UserCreated"}}
+type Registry struct {
+	Mode         string            `json:"mode"`          // backward/forward/full, 默认 backward
+	MessageTypes map[string]string `json:"message_types"` // MessageType -> proto message 全名
+}
+
+// LoadRegistry 读取 dir 下的 registry.json.
+func LoadRegistry(dir string) (Registry, error) {
+	var r Registry
+	bs, err := os.ReadFile(filepath.Join(dir, RegistryFile))
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(bs, &r); err != nil {
+		return r, fmt.Errorf("invalid %s: %w", RegistryFile, err)
+	}
+	return r, nil
+}
+
+// Types 返回 MessageType 到 message 全名的映射.
+func (r Registry) Types() (map[kafka.MessageType]string, error) {
+	types := make(map[kafka.MessageType]string, len(r.MessageTypes))
+	for k, name := range r.MessageTypes {
+		n, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message type %q", k)
+		}
+		types[kafka.MessageType(n)] = name
+	}
+	return types, nil
+}
+
+// CompatibilityMode 返回登记的兼容性规则, 默认 Backward.
+func (r Registry) CompatibilityMode() (Mode, error) {
+	if r.Mode == "" {
+		return Backward, nil
+	}
+	return ParseMode(r.Mode)
+}
+
+// SnapshotPath 返回 msgType 已接受的 schema 快照路径.
+func SnapshotPath(dir string, msgType kafka.MessageType) string {
+	return filepath.Join(dir, msgType.String()+".binpb")
+}
+
+// LoadSnapshot 读取 msgType 已接受的 schema 快照, 没有快照时返回的错误满足 os.IsNotExist.
+func LoadSnapshot(dir string, msgType kafka.MessageType) (*descriptorpb.FileDescriptorSet, error) {
+	return ReadDescriptorSet(SnapshotPath(dir, msgType))
+}
+
+// SaveSnapshot 保存 msgType 已接受的 schema 快照.
+func SaveSnapshot(dir string, msgType kafka.MessageType, set *descriptorpb.FileDescriptorSet) error {
+	bs, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(SnapshotPath(dir, msgType), bs, 0o644)
+}
+
+// ReadDescriptorSet 读取 protoc --include_imports --descriptor_set_out 生成的文件.
+func ReadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(bs, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Extract 从 files 中取出 name 所在的文件以及它依赖的文件, 作为 name 的 schema 快照.
+func Extract(files *protoregistry.Files, name protoreflect.FullName) (*descriptorpb.FileDescriptorSet, error) {
+	md, err := findMessage(files, name)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		// 先添加依赖, 保证文件按依赖顺序排列
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(md.ParentFile())
+	return set, nil
+}
@@ -0,0 +1,68 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Exporter 导出结束的 span, 需要支持并发调用.
+type Exporter interface {
+	ExportSpan(SpanData)
+}
+
+type nopExporter struct{}
+
+func (nopExporter) ExportSpan(SpanData) {}
+
+// NewStdoutExporter 创建一个每行输出一个 span json 的 Exporter, 一般传入 os.Stdout.
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{w: w}
+}
+
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (impl *stdoutExporter) ExportSpan(span SpanData) {
+	bs, err := json.Marshal(span)
+	if err != nil {
+		return
+	}
+	bs = append(bs, '\n')
+	impl.mu.Lock()
+	_, _ = impl.w.Write(bs)
+	impl.mu.Unlock()
+}
+
+// InMemoryExporter 把 span 保存在内存中, 用于测试.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter 创建一个 InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (impl *InMemoryExporter) ExportSpan(span SpanData) {
+	impl.mu.Lock()
+	impl.spans = append(impl.spans, span)
+	impl.mu.Unlock()
+}
+
+// Spans 返回已经导出的 span, 按结束的先后顺序排列.
+func (impl *InMemoryExporter) Spans() []SpanData {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	return append([]SpanData(nil), impl.spans...)
+}
+
+// Reset 清空已经导出的 span.
+func (impl *InMemoryExporter) Reset() {
+	impl.mu.Lock()
+	impl.spans = nil
+	impl.mu.Unlock()
+}
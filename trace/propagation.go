@@ -0,0 +1,97 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader 是 W3C trace context 的请求头.
+const TraceparentHeader = "traceparent"
+
+// Carrier 是 trace context 的载体, 例如 http 请求头, kafka 消息头.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// HeaderCarrier 把 http.Header 适配为 Carrier.
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string { return http.Header(c).Get(key) }
+func (c HeaderCarrier) Set(key, value string) { http.Header(c).Set(key, value) }
+
+// Inject 把 ctx 中的 SpanContext 以 traceparent 的格式写入 carrier.
+func Inject(ctx context.Context, carrier Carrier) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	carrier.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+// Extract 从 carrier 中解析 traceparent, 返回携带远端 SpanContext 的 ctx, 解析失败时返回原 ctx.
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	sc, ok := ParseTraceparent(carrier.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// FormatTraceparent 返回 "00-{trace-id}-{parent-id}-{trace-flags}".
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent 解析 W3C traceparent, 见 https://www.w3.org/TR/trace-context/#traceparent-header.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// 版本 00 必须正好 4 段, 未来的版本允许追加字段; ff 是无效版本
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, false
+	}
+	// hex.Decode 接受大写, traceparent 只允许小写
+	if !isLowerHex(version) || !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return sc, false
+	}
+	var f [1]byte
+	if _, err := hex.Decode(f[:], []byte(flags)); err != nil {
+		return sc, false
+	}
+	if !sc.IsValid() {
+		return sc, false
+	}
+	sc.Sampled = f[0]&0x01 == 0x01
+	sc.Remote = true
+	return sc, true
+}
+
+// isLowerHex 返回 s 是否只包含 [0-9a-f].
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"demo-to-start/logger"
+)
+
+// TraceID 是 W3C trace context 中的 trace-id.
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// SpanID 是 W3C trace context 中的 parent-id/span-id.
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext 是跨进程传递的 span 标识.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool // 是否是从请求头/消息头中解析出来的
+}
+
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// SpanData 是结束的 span 的快照, 交给 Exporter 导出.
+type SpanData struct {
+	Name         string                 `json:"name"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	StartTime    time.Time              `json:"start_time"`
+	EndTime      time.Time              `json:"end_time"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Span 表示一次操作, 必须调用 End 结束, 可以并发使用.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	start  time.Time
+
+	mu    sync.Mutex
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+// SpanContext 返回 span 的标识.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes 设置 span 的属性, keyvals 是交替出现的 key/value, key 必须是 string.
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			continue
+		}
+		if s.attrs == nil {
+			s.attrs = make(map[string]interface{})
+		}
+		s.attrs[key] = keyvals[i+1]
+	}
+}
+
+// RecordError 记录 span 的错误, err 为 nil 时忽略.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End 结束 span 并导出, 重复调用只有第一次生效.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:       s.name,
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		StartTime:  s.start,
+		EndTime:    time.Now(),
+		Attributes: s.attrs,
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if s.err != nil {
+		data.Error = s.err.Error()
+	}
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.exporter.ExportSpan(data)
+	}
+}
+
+// Tracer 创建 span, 结束的 span 交给 Exporter 导出.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer 创建一个 Tracer, exporter 为 nil 时丢弃所有 span, 但仍然会传播 trace context.
+func NewTracer(exporter Exporter) *Tracer {
+	if exporter == nil {
+		exporter = nopExporter{}
+	}
+	return &Tracer{exporter: exporter}
+}
+
+// Start 创建一个 span, ctx 中有 span 或者远端的 SpanContext 时作为父 span.
+//
+// 返回的 ctx 携带新的 span, 同时携带 trace id 供 logger 输出.
+func (t *Tracer) Start(ctx context.Context, name string, keyvals ...interface{}) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{tracer: t, name: name, start: time.Now()}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = true
+	}
+	_, _ = rand.Read(span.sc.SpanID[:])
+	span.SetAttributes(keyvals...)
+
+	ctx = context.WithValue(ctx, spanKey{}, span)
+	ctx = logger.WithTraceID(ctx, span.sc.TraceID.String())
+	return ctx, span
+}
+
+type spanKey struct{}
+
+type remoteSpanContextKey struct{}
+
+// SpanFromContext 返回 ctx 中的 span, 没有则返回 nil, nil *Span 的方法都可以安全调用.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext 返回携带远端 SpanContext 的 ctx, 之后创建的 span 以它为父 span.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	ctx = context.WithValue(ctx, remoteSpanContextKey{}, sc)
+	return logger.WithTraceID(ctx, sc.TraceID.String())
+}
+
+// SpanContextFromContext 返回 ctx 中当前 span 的 SpanContext, 没有 span 时返回远端的 SpanContext.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+var defaultTracer atomic.Value // *Tracer
+
+func init() {
+	defaultTracer.Store(NewTracer(nil))
+}
+
+// Default 返回全局的 Tracer, 默认不导出 span.
+func Default() *Tracer {
+	return defaultTracer.Load().(*Tracer)
+}
+
+// SetDefault 设置全局的 Tracer.
+func SetDefault(t *Tracer) {
+	if t != nil {
+		defaultTracer.Store(t)
+	}
+}
+
+// Start 使用全局的 Tracer 创建 span, 见 Tracer.Start.
+func Start(ctx context.Context, name string, keyvals ...interface{}) (context.Context, *Span) {
+	return Default().Start(ctx, name, keyvals...)
+}
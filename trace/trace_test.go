@@ -0,0 +1,91 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"demo-to-start/logger"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           string
+		wantOK      bool
+		wantSampled bool
+	}{
+		{name: "sampled", s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantOK: true, wantSampled: true},
+		{name: "not sampled", s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", wantOK: true},
+		{name: "future version", s: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantOK: true, wantSampled: true},
+		{name: "version 00 extra", s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "invalid version", s: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", s: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", s: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "not hex", s: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+		{name: "uppercase trace id", s: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "uppercase span id", s: "00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01"},
+		{name: "uppercase version", s: "0A-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "empty", s: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.s)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseTraceparent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && sc.Sampled != tt.wantSampled {
+				t.Errorf("ParseTraceparent() sampled = %v, want %v", sc.Sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	// 上游服务
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := Extract(context.Background(), HeaderCarrier(header))
+	ctx, server := tracer.Start(ctx, "server")
+	if got := logger.TraceIDFromContext(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("logger trace id = %v", got)
+	}
+
+	_, client := tracer.Start(ctx, "client", "k", "v")
+	client.RecordError(errors.New("boom"))
+	client.End()
+
+	out := http.Header{}
+	Inject(ctx, HeaderCarrier(out))
+	server.End()
+	server.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "client" || spans[0].ParentSpanID != spans[1].SpanID || spans[0].Error != "boom" || spans[0].Attributes["k"] != "v" {
+		t.Errorf("unexpected client span %+v", spans[0])
+	}
+	if spans[1].ParentSpanID != "00f067aa0ba902b7" || spans[1].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected server span %+v", spans[1])
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + spans[1].SpanID + "-01"; out.Get(TraceparentHeader) != want {
+		t.Errorf("Inject() = %v, want %v", out.Get(TraceparentHeader), want)
+	}
+}
+
+func TestTracer_NotSampled(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := NewTracer(exporter).Start(ContextWithRemoteSpanContext(context.Background(), sc), "op")
+	span.End()
+	if len(exporter.Spans()) != 0 {
+		t.Errorf("not sampled span exported")
+	}
+}
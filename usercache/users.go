@@ -0,0 +1,369 @@
+// Package usercache 在 model.UserRepository 前面加一层进程内的 LRU 缓存, 减少 Get 对数据库的访问.
+package usercache
+
+import (
+	"context"
+	"database/sql"
+	"demo-to-start/common"
+	"demo-to-start/logger"
+	"demo-to-start/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CacheName 是用户缓存在 cachebus 中的名称.
+const CacheName = "users"
+
+const (
+	defaultSize        = 1024
+	defaultTTL         = time.Minute
+	defaultNegativeTTL = 10 * time.Second
+)
+
+// UserRepositoryConfig 是用户缓存相关配置.
+type UserRepositoryConfig struct {
+	Users        model.UserRepository // 必须; 被缓存的用户存储
+	Size         int                  // 可选; 最多缓存的用户数, 默认 1024
+	TTL          time.Duration        // 可选; 用户的缓存时间, 默认 1m
+	NegativeTTL  time.Duration        // 可选; 用户不存在的缓存时间, 默认 10s, 小于 0 时不缓存
+	Broadcaster  Broadcaster          // 可选; 写操作之后通知其他实例删除缓存, 例如 *cachebus.Broadcaster
+	SnapshotFile string               // 可选; 不为空时 WarmUp 从文件恢复缓存, Close 时保存到文件, 重启之后不需要重新加载
+	Logger       logger.Logger        // 可选; 日志, 默认 logger.Default()
+}
+
+// Broadcaster 通知其他实例删除缓存.
+type Broadcaster interface {
+	Invalidate(ctx context.Context, cache string, keys ...string) error
+}
+
+// Stats 是缓存的统计数据, 所有计数从创建开始累加.
+type Stats struct {
+	Hits          uint64 // 命中的用户
+	NegativeHits  uint64 // 命中的用户不存在
+	Misses        uint64 // 未命中或者已过期
+	Loads         uint64 // 访问被缓存的存储的次数, 并发的相同 id 只访问一次
+	Coalesced     uint64 // 等待其他请求加载结果的次数
+	Invalidations uint64 // 写操作和其他实例通知导致的失效次数
+	Size          int    // 当前缓存的条目数
+}
+
+// UserRepository 是带缓存的 model.UserRepository, 只缓存 Get, 写操作之后删除对应的缓存.
+//
+// 缓存只在当前进程内有效, 没有配置 Broadcaster 时, 其他进程修改用户之后, 最多 TTL 之后才能读到.
+type UserRepository struct {
+	users       model.UserRepository
+	ttl         time.Duration
+	negativeTTL time.Duration
+	broadcaster Broadcaster
+	logger      logger.Logger
+	now         func() time.Time
+	snapshot    string
+	warm        common.Bool // WarmUp 已经完成, 没有配置 SnapshotFile 时创建之后就是 true
+
+	mu       sync.Mutex
+	lru      *common.LRU[int, *entry]
+	inflight map[int]*call // 失效时删除, 加载结束时不在其中的结果不写入缓存
+
+	hits, negativeHits, misses, loads, coalesced, invalidations common.Uint64
+	loadErrors                                                  common.Uint64
+	loadTime                                                    common.Duration
+}
+
+type entry struct {
+	user    model.User
+	err     error // 只会是 sql.ErrNoRows
+	expires time.Time
+}
+
+// call 是一次正在进行的加载, 相同 id 的并发请求等待同一个结果.
+type call struct {
+	done chan struct{}
+	user model.User
+	err  error
+}
+
+// NewUserRepository 创建带缓存的用户存储.
+func NewUserRepository(config UserRepositoryConfig) (*UserRepository, error) {
+	if config.Users == nil {
+		return nil, errors.New("nil user repository")
+	}
+	if config.Size <= 0 {
+		config.Size = defaultSize
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	if config.NegativeTTL == 0 {
+		config.NegativeTTL = defaultNegativeTTL
+	}
+	if config.Logger == nil {
+		config.Logger = logger.Default()
+	}
+	r := &UserRepository{
+		users:       config.Users,
+		ttl:         config.TTL,
+		negativeTTL: config.NegativeTTL,
+		broadcaster: config.Broadcaster,
+		logger:      config.Logger,
+		now:         time.Now,
+		snapshot:    config.SnapshotFile,
+		lru:         common.NewLRUWithConfig(common.LRUConfig[int, *entry]{Max: config.Size, ValueCodec: entryCodec{}}),
+		inflight:    make(map[int]*call),
+	}
+	r.warm.Store(r.snapshot == "")
+	return r, nil
+}
+
+// ErrWarmingUp 表示缓存还在预热, 见 UserRepository.Check.
+var ErrWarmingUp = errors.New("user cache warming up")
+
+// WarmUp 在配置了 SnapshotFile 时从文件恢复缓存, 启动服务之前调用.
+// 快照损坏或者版本不兼容时只是少了预热, 打印日志之后继续, 不影响启动.
+func (r *UserRepository) WarmUp(ctx context.Context) {
+	if r.snapshot != "" {
+		if err := common.LoadSnapshotFile(r.snapshot, r); err != nil {
+			r.logger.Warn(ctx, "restore-user-cache-failed", "file", r.snapshot, "error", err.Error())
+		}
+	}
+	r.warm.Store(true)
+}
+
+// Check 在 WarmUp 完成之前返回 ErrWarmingUp, 实现 health.Checker, 预热完成之前不接收流量, 防止请求都访问数据库.
+func (r *UserRepository) Check(context.Context) error {
+	if !r.warm.Load() {
+		return ErrWarmingUp
+	}
+	return nil
+}
+
+// Get 优先返回缓存的用户, 未命中时从存储加载, 相同 id 的并发请求只加载一次.
+func (r *UserRepository) Get(ctx context.Context, id int) (model.User, error) {
+	r.mu.Lock()
+	if e, ok := r.lru.Get(id); ok {
+		if r.now().Before(e.expires) {
+			r.mu.Unlock()
+			if e.err != nil {
+				r.negativeHits.Inc()
+				return model.User{}, e.err
+			}
+			r.hits.Inc()
+			return e.user, nil
+		}
+		_ = r.lru.Remove(id)
+	}
+	r.misses.Inc()
+
+	c, ok := r.inflight[id]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		r.inflight[id] = c
+		go r.load(ctx, id, c)
+	} else {
+		r.coalesced.Inc()
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.user, c.err
+	case <-ctx.Done():
+		return model.User{}, ctx.Err()
+	}
+}
+
+// load 从存储加载用户并写入缓存. 加载不使用调用方的取消, 防止一个请求取消导致等待同一结果的其他请求失败.
+func (r *UserRepository) load(ctx context.Context, id int, c *call) {
+	r.loads.Inc()
+	start := time.Now()
+	c.user, c.err = r.users.Get(context.WithoutCancel(ctx), id)
+	r.loadTime.Add(time.Since(start))
+	if c.err != nil && !errors.Is(c.err, sql.ErrNoRows) {
+		r.loadErrors.Inc()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 加载期间 id 被失效过时 inflight[id] 已经不是 c, 结果可能是旧的, 不写入缓存
+	if r.inflight[id] == c {
+		delete(r.inflight, id)
+		switch {
+		case c.err == nil:
+			_ = r.lru.Add(id, &entry{user: c.user, expires: r.now().Add(r.ttl)})
+		case errors.Is(c.err, sql.ErrNoRows) && r.negativeTTL > 0:
+			_ = r.lru.Add(id, &entry{err: c.err, expires: r.now().Add(r.negativeTTL)})
+		}
+	}
+	close(c.done)
+}
+
+// List 不使用缓存.
+func (r *UserRepository) List(ctx context.Context, offset, limit int) ([]model.User, error) {
+	return r.users.List(ctx, offset, limit)
+}
+
+// Create 创建用户, 新用户的 id 之前可能被缓存为不存在, 所以也需要失效.
+func (r *UserRepository) Create(ctx context.Context, user model.User) (model.User, error) {
+	user, err := r.users.Create(ctx, user)
+	if err == nil {
+		r.invalidateAndBroadcast(ctx, user.ID)
+	}
+	return user, err
+}
+
+// Update 更新用户并删除缓存, 失败时也删除, 因为无法确定存储中是否已经修改.
+func (r *UserRepository) Update(ctx context.Context, user model.User) error {
+	defer r.invalidateAndBroadcast(ctx, user.ID)
+	return r.users.Update(ctx, user)
+}
+
+// Delete 删除用户并删除缓存.
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	defer r.invalidateAndBroadcast(ctx, id)
+	return r.users.Delete(ctx, id)
+}
+
+// invalidateAndBroadcast 删除本地缓存并通知其他实例, 通知失败只打印日志, 其他实例最多 TTL 之后读到新的数据.
+func (r *UserRepository) invalidateAndBroadcast(ctx context.Context, id int) {
+	r.Invalidate(id)
+	if r.broadcaster == nil {
+		return
+	}
+	if err := r.broadcaster.Invalidate(ctx, CacheName, strconv.Itoa(id)); err != nil {
+		r.logger.Warn(ctx, "broadcast-user-cache-invalidate-failed", "user_id", id, "error", err.Error())
+	}
+}
+
+// Invalidate 删除 id 的缓存, 正在进行的加载结果也不会写入缓存.
+func (r *UserRepository) Invalidate(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.lru.Remove(id)
+	delete(r.inflight, id)
+	r.invalidations.Inc()
+}
+
+// InvalidateKeys 删除其他实例通知的 id, 实现 cachebus.Invalidator.
+func (r *UserRepository) InvalidateKeys(keys []string) {
+	for _, key := range keys {
+		if id, err := strconv.Atoi(key); err == nil {
+			r.Invalidate(id)
+		}
+	}
+}
+
+// InvalidateAll 删除全部缓存, 实现 cachebus.Invalidator.
+func (r *UserRepository) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lru.Purge()
+	r.inflight = make(map[int]*call)
+	r.invalidations.Inc()
+}
+
+// Snapshot 把缓存的用户写入 w, 实现 common.Snapshotter.
+func (r *UserRepository) Snapshot(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lru.Snapshot(w)
+}
+
+// Restore 从 Snapshot 写入的数据中恢复缓存, 已经过期的用户被跳过, 实现 common.Snapshotter.
+func (r *UserRepository) Restore(rd io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.lru.Restore(rd)
+	now := r.now()
+	for _, id := range r.lru.Keys() {
+		if e, _ := r.lru.Peek(id); !now.Before(e.expires) {
+			_ = r.lru.Remove(id)
+		}
+	}
+	return err
+}
+
+// Close 在配置了 SnapshotFile 时把缓存保存到文件, 在停止服务时调用.
+func (r *UserRepository) Close() error {
+	if r.snapshot == "" {
+		return nil
+	}
+	if err := common.SaveSnapshotFile(r.snapshot, r); err != nil {
+		return fmt.Errorf("save user cache snapshot: %w", err)
+	}
+	return nil
+}
+
+// entryCodec 编码缓存的条目, 不存在的用户只保存过期时间.
+type entryCodec struct{}
+
+type snapshotEntry struct {
+	User    *model.User `json:"user,omitempty"`
+	Expires time.Time   `json:"expires"`
+}
+
+func (entryCodec) Encode(e *entry) ([]byte, error) {
+	s := snapshotEntry{Expires: e.expires}
+	if e.err == nil {
+		s.User = &e.user
+	}
+	return json.Marshal(s)
+}
+
+func (entryCodec) Decode(data []byte) (*entry, error) {
+	var s snapshotEntry
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.User == nil {
+		return &entry{err: sql.ErrNoRows, expires: s.Expires}, nil
+	}
+	return &entry{user: *s.User, expires: s.Expires}, nil
+}
+
+// Stats 返回缓存的统计数据.
+func (r *UserRepository) Stats() Stats {
+	r.mu.Lock()
+	size := r.lru.Len()
+	r.mu.Unlock()
+	return Stats{
+		Hits:          r.hits.Load(),
+		NegativeHits:  r.negativeHits.Load(),
+		Misses:        r.misses.Load(),
+		Loads:         r.loads.Load(),
+		Coalesced:     r.coalesced.Load(),
+		Invalidations: r.invalidations.Load(),
+		Size:          size,
+	}
+}
+
+// CacheReporter 返回用于注册到 common.CacheRegistry 的统计数据, 同时支持查看热点用户.
+func (r *UserRepository) CacheReporter() common.StatsReporter {
+	return cacheReporter{r}
+}
+
+type cacheReporter struct {
+	r *UserRepository
+}
+
+// Stats 命中和未命中按用户缓存自己的过期时间计算, 不存在的用户也算命中; 插入和移除来自底层的 LRU.
+func (c cacheReporter) Stats() common.CacheStats {
+	c.r.mu.Lock()
+	stats := c.r.lru.Stats()
+	c.r.mu.Unlock()
+	stats.Hits = c.r.hits.Load() + c.r.negativeHits.Load()
+	stats.Misses = c.r.misses.Load()
+	stats.Loads = c.r.loads.Load()
+	stats.LoadErrors = c.r.loadErrors.Load()
+	stats.LoadTime = c.r.loadTime.Load()
+	return stats
+}
+
+func (c cacheReporter) HotKeys(n int) []common.HotKey {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	return c.r.lru.HotKeys(n)
+}
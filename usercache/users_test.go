@@ -0,0 +1,275 @@
+package usercache
+
+import (
+	"context"
+	"database/sql"
+	"demo-to-start/common"
+	"demo-to-start/memstore"
+	"demo-to-start/model"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingUserRepository 记录 Get 的次数, gate 不为 nil 时 Get 等待 gate 关闭.
+type countingUserRepository struct {
+	model.UserRepository
+	gets int64
+	gate chan struct{}
+}
+
+func (r *countingUserRepository) Get(ctx context.Context, id int) (model.User, error) {
+	atomic.AddInt64(&r.gets, 1)
+	if r.gate != nil {
+		<-r.gate
+	}
+	return r.UserRepository.Get(ctx, id)
+}
+
+func newTestRepository(t *testing.T, config UserRepositoryConfig) (*UserRepository, *countingUserRepository, *time.Time) {
+	t.Helper()
+	backend := &countingUserRepository{UserRepository: memstore.NewUserRepository(model.User{ID: 1, Name: "alice", Age: 20})}
+	config.Users = backend
+	r, err := NewUserRepository(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	r.now = func() time.Time { return now }
+	return r, backend, &now
+}
+
+func TestUserRepository_Get(t *testing.T) {
+	ctx := context.Background()
+	r, backend, now := newTestRepository(t, UserRepositoryConfig{TTL: time.Minute, NegativeTTL: time.Second})
+
+	for i := 0; i < 3; i++ {
+		if user, err := r.Get(ctx, 1); err != nil || user.Name != "alice" {
+			t.Fatalf("Get(1) = %+v, %v", user, err)
+		}
+		if _, err := r.Get(ctx, 2); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Get(2) error = %v, want sql.ErrNoRows", err)
+		}
+	}
+	if backend.gets != 2 {
+		t.Errorf("backend gets = %d, want 2", backend.gets)
+	}
+
+	// 不存在的缓存先过期
+	*now = now.Add(2 * time.Second)
+	_, _ = r.Get(ctx, 1)
+	_, _ = r.Get(ctx, 2)
+	if backend.gets != 3 {
+		t.Errorf("backend gets after negative ttl = %d, want 3", backend.gets)
+	}
+	*now = now.Add(time.Minute)
+	_, _ = r.Get(ctx, 1)
+	if backend.gets != 4 {
+		t.Errorf("backend gets after ttl = %d, want 4", backend.gets)
+	}
+
+	want := Stats{Hits: 3, NegativeHits: 2, Misses: 4, Loads: 4, Size: 2}
+	if got := r.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// 注册到 common.CacheRegistry 的统计数据, 不存在的用户也算命中
+	stats := r.CacheReporter().Stats()
+	if stats.Hits != 5 || stats.Misses != 4 || stats.Loads != 4 || stats.LoadErrors != 0 || stats.Size != 2 || stats.Max != defaultSize {
+		t.Errorf("CacheReporter().Stats() = %+v", stats)
+	}
+	_, _ = r.Get(ctx, 1)
+	if hot := r.CacheReporter().(common.HotKeysReporter).HotKeys(1); len(hot) != 1 || hot[0].Key != "1" {
+		t.Errorf("HotKeys(1) = %v", hot)
+	}
+}
+
+func TestUserRepository_DisableNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	r, backend, _ := newTestRepository(t, UserRepositoryConfig{NegativeTTL: -1})
+	_, _ = r.Get(ctx, 2)
+	_, _ = r.Get(ctx, 2)
+	if backend.gets != 2 {
+		t.Errorf("backend gets = %d, want 2", backend.gets)
+	}
+}
+
+func TestUserRepository_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	r, backend, _ := newTestRepository(t, UserRepositoryConfig{})
+
+	_, _ = r.Get(ctx, 1)
+	if err := r.Update(ctx, model.User{ID: 1, Name: "alice", Age: 21}); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := r.Get(ctx, 1); user.Age != 21 {
+		t.Errorf("Get() after Update = %+v", user)
+	}
+
+	// 创建的用户 id 之前被缓存为不存在
+	_, _ = r.Get(ctx, 2)
+	if _, err := r.Create(ctx, model.User{Name: "bob", Age: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := r.Get(ctx, 2); err != nil || user.Name != "bob" {
+		t.Errorf("Get() after Create = %+v, %v", user, err)
+	}
+
+	if err := r.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, 2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get() after Delete error = %v", err)
+	}
+	if backend.gets != 5 {
+		t.Errorf("backend gets = %d, want 5", backend.gets)
+	}
+	if got := r.Stats().Invalidations; got != 3 {
+		t.Errorf("Stats().Invalidations = %d, want 3", got)
+	}
+}
+
+func TestUserRepository_Singleflight(t *testing.T) {
+	ctx := context.Background()
+	r, backend, _ := newTestRepository(t, UserRepositoryConfig{})
+	backend.gate = make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if user, err := r.Get(ctx, 1); err != nil || user.Name != "alice" {
+				t.Errorf("Get() = %+v, %v", user, err)
+			}
+		}()
+	}
+	// 等待所有请求都在等待同一个加载
+	for r.Stats().Misses != n {
+		time.Sleep(time.Millisecond)
+	}
+	close(backend.gate)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&backend.gets); got != 1 {
+		t.Errorf("backend gets = %d, want 1", got)
+	}
+	if got := r.Stats(); got.Loads != 1 || got.Coalesced != n-1 {
+		t.Errorf("Stats() = %+v", got)
+	}
+}
+
+func TestUserRepository_InvalidateDuringLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(r *UserRepository)
+		wantSize   int
+	}{
+		// 失效之前开始的加载结果不能写入缓存
+		{name: "same id", invalidate: func(r *UserRepository) { r.Invalidate(1) }, wantSize: 0},
+		{name: "all", invalidate: func(r *UserRepository) { r.InvalidateAll() }, wantSize: 0},
+		// 其他 id 的失效不影响正在进行的加载
+		{name: "other id", invalidate: func(r *UserRepository) { r.Invalidate(2) }, wantSize: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, backend, _ := newTestRepository(t, UserRepositoryConfig{})
+			backend.gate = make(chan struct{})
+
+			done := make(chan model.User)
+			go func() {
+				user, _ := r.Get(ctx, 1)
+				done <- user
+			}()
+			for r.Stats().Misses != 1 {
+				time.Sleep(time.Millisecond)
+			}
+			tt.invalidate(r)
+			close(backend.gate)
+			<-done
+
+			if got := r.Stats().Size; got != tt.wantSize {
+				t.Errorf("Stats().Size = %d, want %d", got, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestUserRepository_GetCanceled(t *testing.T) {
+	r, backend, _ := newTestRepository(t, UserRepositoryConfig{})
+	backend.gate = make(chan struct{})
+	defer close(backend.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Get(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+type recordingBroadcaster struct {
+	keys []string
+}
+
+func (b *recordingBroadcaster) Invalidate(_ context.Context, cache string, keys ...string) error {
+	for _, key := range keys {
+		b.keys = append(b.keys, cache+":"+key)
+	}
+	return nil
+}
+
+func TestUserRepository_Broadcast(t *testing.T) {
+	ctx := context.Background()
+	broadcaster := &recordingBroadcaster{}
+	r, backend, _ := newTestRepository(t, UserRepositoryConfig{Broadcaster: broadcaster})
+
+	_ = r.Update(ctx, model.User{ID: 1, Name: "alice", Age: 21})
+	_ = r.Delete(ctx, 5)
+	if want := []string{"users:1", "users:5"}; !reflect.DeepEqual(broadcaster.keys, want) {
+		t.Errorf("broadcast keys = %v, want %v", broadcaster.keys, want)
+	}
+
+	// 其他实例的通知只删除本地缓存, 不再广播
+	_, _ = r.Get(ctx, 1)
+	r.InvalidateKeys([]string{"1", "abc"})
+	_, _ = r.Get(ctx, 1)
+	r.InvalidateAll()
+	_, _ = r.Get(ctx, 1)
+	if backend.gets != 3 || len(broadcaster.keys) != 2 {
+		t.Errorf("backend gets = %d, broadcast keys = %v", backend.gets, broadcaster.keys)
+	}
+}
+
+func TestUserRepository_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "users.snapshot")
+	r, _, now := newTestRepository(t, UserRepositoryConfig{SnapshotFile: file, TTL: time.Minute, NegativeTTL: time.Second})
+	_, _ = r.Get(ctx, 1)
+	_, _ = r.Get(ctx, 2)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启之后直接命中, 不存在的用户已经过期
+	restored, backend, _ := newTestRepository(t, UserRepositoryConfig{SnapshotFile: file})
+	if err := restored.Check(ctx); !errors.Is(err, ErrWarmingUp) {
+		t.Errorf("Check() before WarmUp error = %v", err)
+	}
+	restored.now = func() time.Time { return now.Add(2 * time.Second) }
+	restored.WarmUp(ctx)
+	if err := restored.Check(ctx); err != nil {
+		t.Errorf("Check() after WarmUp error = %v", err)
+	}
+	if user, err := restored.Get(ctx, 1); err != nil || user.Name != "alice" {
+		t.Errorf("Get(1) = %+v, %v", user, err)
+	}
+	if got := restored.Stats(); got.Hits != 1 || got.Size != 1 || backend.gets != 0 {
+		t.Errorf("Stats() = %+v, backend gets = %d", got, backend.gets)
+	}
+}